@@ -0,0 +1,163 @@
+// Code generated by ybase-lexgen from calc.l. DO NOT EDIT.
+
+package example
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/berquerant/ybase"
+)
+
+// tokens
+const (
+	NUM   = 57346
+	IDENT = 57347
+)
+
+// yyLexRegistry names the tokens.
+var yyLexRegistry = ybase.NewRegistry().
+	Register(NUM, "NUM", ybase.CategoryUnknown).
+	Register(IDENT, "IDENT", ybase.CategoryUnknown)
+
+// start states
+const (
+	INITIAL = ybase.InitialState
+	COMMENT = "COMMENT"
+)
+
+// yyLexStates are the indexes of the tables for each state.
+var yyLexStates = map[string]int{
+	INITIAL: 0,
+	COMMENT: 1,
+}
+
+// yyLexRules are the indexes of the rules active in each state.
+var yyLexRules = [...][]int{
+	{0, 1, 4, 5, 6, 7, 8, 9}, // INITIAL
+	{2, 3},                   // COMMENT
+}
+
+// yyLexDFA are the DFAs of the rules active in each state.
+var yyLexDFA = [...]*ybase.DFA{
+	{ // INITIAL
+		States: []ybase.DFAState{
+			{Accept: -1, Transitions: []ybase.DFATransition{{Lo: 9, Hi: 10, Next: 1}, {Lo: 32, Hi: 32, Next: 1}, {Lo: 40, Hi: 40, Next: 2}, {Lo: 41, Hi: 41, Next: 3}, {Lo: 43, Hi: 43, Next: 4}, {Lo: 45, Hi: 45, Next: 5}, {Lo: 47, Hi: 47, Next: 6}, {Lo: 48, Hi: 57, Next: 7}, {Lo: 97, Hi: 122, Next: 8}}},
+			{Accept: 0, Transitions: []ybase.DFATransition{{Lo: 9, Hi: 10, Next: 1}, {Lo: 32, Hi: 32, Next: 1}}},
+			{Accept: 6},
+			{Accept: 7},
+			{Accept: 4},
+			{Accept: 5},
+			{Accept: -1, Transitions: []ybase.DFATransition{{Lo: 42, Hi: 42, Next: 9}}},
+			{Accept: 2, Transitions: []ybase.DFATransition{{Lo: 48, Hi: 57, Next: 7}}},
+			{Accept: 3, Transitions: []ybase.DFATransition{{Lo: 48, Hi: 57, Next: 8}, {Lo: 97, Hi: 122, Next: 8}}},
+			{Accept: 1},
+		},
+	},
+	{ // COMMENT
+		States: []ybase.DFAState{
+			{Accept: -1, Transitions: []ybase.DFATransition{{Lo: 0, Hi: 41, Next: 1}, {Lo: 42, Hi: 42, Next: 2}, {Lo: 43, Hi: 1114111, Next: 1}}},
+			{Accept: 1},
+			{Accept: 1, Transitions: []ybase.DFATransition{{Lo: 47, Hi: 47, Next: 3}}},
+			{Accept: 0},
+		},
+	},
+}
+
+// yyNewScanFunc returns a new ScanFunc generated from the spec.
+func yyNewScanFunc() ybase.ScanFunc {
+	return func(r ybase.Reader) int {
+		for {
+			if r.Peek() == ybase.EOF {
+				return ybase.EOF
+			}
+			state, ok := yyLexStates[r.CurrentState()]
+			if !ok {
+				r.Errorf(ybase.ErrUnknownState, fmt.Sprintf("no rules for %s", r.CurrentState()))
+				return ybase.EOF
+			}
+			index, size := yyLexDFA[state].Match(r)
+			if index < 0 {
+				r.Errorf(ybase.ErrNoRuleMatched, fmt.Sprintf("unexpected %q", r.Peek()))
+				return ybase.EOF
+			}
+			switch yyLexRules[state][index] {
+			case 0: // line 6
+				for range size {
+					_ = r.Discard()
+				}
+			case 1: // line 7
+				for range size {
+					_ = r.Next()
+				}
+				{
+					r.PushState(COMMENT)
+				}
+				r.ResetBuffer()
+			case 2: // line 8
+				for range size {
+					_ = r.Next()
+				}
+				{
+					r.PopState()
+				}
+				r.ResetBuffer()
+			case 3: // line 9
+				for range size {
+					_ = r.Discard()
+				}
+			case 4: // line 10
+				for range size {
+					_ = r.Next()
+				}
+				return NUM
+			case 5: // line 11
+				for range size {
+					_ = r.Next()
+				}
+				return IDENT
+			case 6: // line 12
+				for range size {
+					_ = r.Next()
+				}
+				return '+'
+			case 7: // line 13
+				for range size {
+					_ = r.Next()
+				}
+				return '-'
+			case 8: // line 14
+				for range size {
+					_ = r.Next()
+				}
+				return '('
+			case 9: // line 15
+				for range size {
+					_ = r.Next()
+				}
+				return ')'
+			}
+		}
+	}
+}
+
+// Lexer implements yyLexer.
+type Lexer struct {
+	ybase.Lexer
+}
+
+// NewLexer returns a new Lexer.
+func NewLexer(rdr io.Reader, debugFunc ybase.DebugFunc) *Lexer {
+	return &Lexer{
+		Lexer: ybase.NewLexer(
+			ybase.NewScanner(ybase.NewReader(rdr, debugFunc), yyNewScanFunc()),
+			ybase.WithRegistry(yyLexRegistry),
+		),
+	}
+}
+
+func (l *Lexer) Lex(lval *yySymType) int {
+	return l.DoLex(func(tok ybase.Token) {
+		lval.token = tok
+	})
+}
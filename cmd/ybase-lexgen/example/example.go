@@ -0,0 +1,11 @@
+// Package example is a lexer generated by ybase-lexgen.
+package example
+
+import "github.com/berquerant/ybase"
+
+//go:generate go run github.com/berquerant/ybase/cmd/ybase-lexgen -o calc_lexer.go calc.l
+
+// yySymType is usually generated by goyacc.
+type yySymType struct {
+	token ybase.Token
+}
@@ -0,0 +1,37 @@
+package example
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLexer(t *testing.T) {
+	type token struct {
+		t int
+		v string
+	}
+	lexer := NewLexer(bytes.NewBufferString("1 + /* 2 * 3 */ x1 - (45)"), nil)
+	var (
+		got  []token
+		lval yySymType
+	)
+	for x := lexer.Lex(&lval); x > 0; x = lexer.Lex(&lval) {
+		assert.Equal(t, x, lval.token.Type())
+		if x == NUM {
+			assert.Equal(t, "NUM", lval.token.Kind().Name)
+		}
+		got = append(got, token{t: x, v: lval.token.Value()})
+	}
+	assert.Nil(t, lexer.Err())
+	assert.Equal(t, []token{
+		{NUM, "1"},
+		{'+', "+"},
+		{IDENT, "x1"},
+		{'-', "-"},
+		{'(', "("},
+		{NUM, "45"},
+		{')', ")"},
+	}, got)
+}
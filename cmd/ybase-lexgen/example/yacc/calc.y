@@ -0,0 +1,49 @@
+%{
+package yacc
+
+import (
+	"strconv"
+
+	"github.com/berquerant/ybase"
+)
+%}
+
+%union {
+	token ybase.Token
+	value int
+}
+
+%token <token> NUM IDENT
+%type <value> expr
+
+%left '+' '-'
+
+%%
+
+program
+	: expr
+	{
+		yylex.(*calc).result = $1
+	}
+
+expr
+	: NUM
+	{
+		$$, _ = strconv.Atoi($1.Value())
+	}
+	| IDENT
+	{
+		$$ = yylex.(*calc).vars[$1.Value()]
+	}
+	| expr '+' expr
+	{
+		$$ = $1 + $3
+	}
+	| expr '-' expr
+	{
+		$$ = $1 - $3
+	}
+	| '(' expr ')'
+	{
+		$$ = $2
+	}
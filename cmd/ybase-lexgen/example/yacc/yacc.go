@@ -0,0 +1,27 @@
+// Package yacc is a lexer generated by ybase-lexgen for a parser generated by goyacc.
+//
+// calc.l declares %extern because y.go declares the token constants.
+package yacc
+
+import "io"
+
+//go:generate goyacc -o y.go -v "" calc.y
+//go:generate go run github.com/berquerant/ybase/cmd/ybase-lexgen -o calc_lexer.go calc.l
+
+type calc struct {
+	*Lexer
+	vars   map[string]int
+	result int
+}
+
+// Eval evaluates the expression, the identifiers are the values of vars.
+func Eval(rdr io.Reader, vars map[string]int) (int, error) {
+	c := &calc{
+		Lexer: NewLexer(rdr, nil),
+		vars:  vars,
+	}
+	if yyParse(c) != 0 || c.Err() != nil {
+		return 0, c.Err()
+	}
+	return c.result, nil
+}
@@ -0,0 +1,50 @@
+package yacc
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/berquerant/ybase"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEval(t *testing.T) {
+	vars := map[string]int{"x1": 10}
+	for _, tc := range []struct {
+		title string
+		input string
+		want  int
+		err   error
+	}{
+		{
+			title: "expr",
+			input: "1 + /* 2 * 3 */ x1 - (45 - 4)",
+			want:  -30,
+		},
+		{
+			title: "unknown variable",
+			input: "y",
+			want:  0,
+		},
+		{
+			title: "syntax error",
+			input: "1 +",
+			err:   ybase.ErrSyntax,
+		},
+		{
+			title: "lex error",
+			input: "1 * 2",
+			err:   ybase.ErrNoRuleMatched,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			got, err := Eval(bytes.NewBufferString(tc.input), vars)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+
+	"github.com/berquerant/ybase"
+)
+
+// Generate generates the go source of the lexer from the spec.
+func Generate(spec *Spec, specName string) ([]byte, error) {
+	var (
+		b      bytes.Buffer
+		p      = spec.Prefix
+		states = spec.AllStates()
+	)
+	pf := func(format string, v ...any) { fmt.Fprintf(&b, format, v...) }
+
+	pf("// Code generated by ybase-lexgen from %s. DO NOT EDIT.\n\n", specName)
+	pf("package %s\n\n", spec.Package)
+	pf("import (\n\"fmt\"\n\"io\"\n\n\"github.com/berquerant/ybase\"\n)\n\n")
+	if spec.Code != "" {
+		pf("%s\n", spec.Code)
+	}
+
+	if len(spec.Tokens) > 0 && !spec.Extern {
+		pf("// tokens\nconst (\n")
+		for i, x := range spec.Tokens {
+			pf("%s = %d\n", x, ybase.FirstYaccToken+i)
+		}
+		pf(")\n\n")
+	}
+
+	pf("// %sLexRegistry names the tokens.\n", p)
+	pf("var %sLexRegistry = ybase.NewRegistry()", p)
+	for _, x := range spec.Tokens {
+		pf(".\nRegister(%s, %q, ybase.CategoryUnknown)", x, x)
+	}
+	pf("\n\n")
+
+	pf("// start states\nconst (\n")
+	for _, x := range states {
+		if x == ybase.InitialState {
+			pf("%s = ybase.InitialState\n", x)
+			continue
+		}
+		pf("%s = %q\n", x, x)
+	}
+	pf(")\n\n")
+
+	pf("// %sLexStates are the indexes of the tables for each state.\n", p)
+	pf("var %sLexStates = map[string]int{\n", p)
+	for i, x := range states {
+		pf("%s: %d,\n", x, i)
+	}
+	pf("}\n\n")
+
+	// rules and DFA for each state
+	pf("// %sLexRules are the indexes of the rules active in each state.\n", p)
+	pf("var %sLexRules = [...][]int{\n", p)
+	dfas := make([]*ybase.DFA, len(states))
+	for i, state := range states {
+		var (
+			indexes []string
+			rules   []ybase.RegexpRule
+		)
+		for j, r := range spec.Rules {
+			if r.Active(state) {
+				indexes = append(indexes, fmt.Sprint(j))
+				rules = append(rules, ybase.RegexpRule{Pattern: r.Pattern})
+			}
+		}
+		d, err := ybase.CompileDFA(rules...)
+		if err != nil {
+			return nil, fmt.Errorf("%w: state %s: %w", ErrSpec, state, err)
+		}
+		dfas[i] = d
+		pf("{%s}, // %s\n", strings.Join(indexes, ", "), state)
+	}
+	pf("}\n\n")
+
+	pf("// %sLexDFA are the DFAs of the rules active in each state.\n", p)
+	pf("var %sLexDFA = [...]*ybase.DFA{\n", p)
+	for i, d := range dfas {
+		pf("{ // %s\nStates: []ybase.DFAState{\n", states[i])
+		for _, s := range d.States {
+			if len(s.Transitions) == 0 {
+				pf("{Accept: %d},\n", s.Accept)
+				continue
+			}
+			pf("{Accept: %d, Transitions: []ybase.DFATransition{", s.Accept)
+			for k, t := range s.Transitions {
+				if k > 0 {
+					pf(", ")
+				}
+				pf("{Lo: %d, Hi: %d, Next: %d}", t.Lo, t.Hi, t.Next)
+			}
+			pf("}},\n")
+		}
+		pf("},\n},\n")
+	}
+	pf("}\n\n")
+
+	pf(`// %[1]sNewScanFunc returns a new ScanFunc generated from the spec.
+func %[1]sNewScanFunc() ybase.ScanFunc {
+	return func(r ybase.Reader) int {
+		for {
+			if r.Peek() == ybase.EOF {
+				return ybase.EOF
+			}
+			state, ok := %[1]sLexStates[r.CurrentState()]
+			if !ok {
+				r.Errorf(ybase.ErrUnknownState, fmt.Sprintf("no rules for %%s", r.CurrentState()))
+				return ybase.EOF
+			}
+			index, size := %[1]sLexDFA[state].Match(r)
+			if index < 0 {
+				r.Errorf(ybase.ErrNoRuleMatched, fmt.Sprintf("unexpected %%q", r.Peek()))
+				return ybase.EOF
+			}
+			switch %[1]sLexRules[state][index] {
+`, p)
+	for i, r := range spec.Rules {
+		pf("case %d: // line %d\n", i, r.Line)
+		switch r.Action.Kind {
+		case ActionSkip:
+			pf("for range size {\n_ = r.Discard()\n}\n")
+		case ActionReturn:
+			pf("for range size {\n_ = r.Next()\n}\nreturn %s\n", r.Action.Value)
+		case ActionCode:
+			pf("for range size {\n_ = r.Next()\n}\n%s\nr.ResetBuffer()\n", r.Action.Value)
+		}
+	}
+	pf("}\n}\n}\n}\n\n")
+
+	pf(`// %[2]s implements %[1]sLexer.
+type %[2]s struct {
+	ybase.Lexer
+}
+
+// New%[2]s returns a new %[2]s.
+func New%[2]s(rdr io.Reader, debugFunc ybase.DebugFunc) *%[2]s {
+	return &%[2]s{
+		Lexer: ybase.NewLexer(
+			ybase.NewScanner(ybase.NewReader(rdr, debugFunc), %[1]sNewScanFunc()),
+			ybase.WithRegistry(%[1]sLexRegistry),
+		),
+	}
+}
+
+func (l *%[2]s) Lex(lval *%[1]sSymType) int {
+	return l.DoLex(func(tok ybase.Token) {
+		lval.%[3]s = tok
+	})
+}
+`, p, spec.Lexer, spec.Field)
+
+	if spec.Trailer != "" {
+		pf("\n%s\n", spec.Trailer)
+	}
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("%w: format generated code: %w", ErrSpec, err)
+	}
+	return src, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	for _, dir := range []string{"example", "example/yacc"} {
+		t.Run(dir, func(t *testing.T) {
+			src, err := os.ReadFile(filepath.Join(dir, "calc.l"))
+			if !assert.Nil(t, err) {
+				return
+			}
+			spec, err := ParseSpec("calc.l", src)
+			if !assert.Nil(t, err) {
+				return
+			}
+			got, err := Generate(spec, "calc.l")
+			if !assert.Nil(t, err) {
+				return
+			}
+			want, err := os.ReadFile(filepath.Join(dir, "calc_lexer.go"))
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, string(want), string(got), "go generate ./... to update")
+		})
+	}
+
+	t.Run("extern", func(t *testing.T) {
+		spec, err := ParseSpec("x.l", []byte("%package x\n%token NUM\n%extern\n%%\n[0-9]+ NUM\n"))
+		if !assert.Nil(t, err) {
+			return
+		}
+		got, err := Generate(spec, "x.l")
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.NotContains(t, string(got), "NUM = ")
+		assert.Contains(t, string(got), `Register(NUM, "NUM", ybase.CategoryUnknown)`)
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		spec, err := ParseSpec("x.l", []byte("%package x\n%%\n(a skip\n"))
+		if !assert.Nil(t, err) {
+			return
+		}
+		_, err = Generate(spec, "x.l")
+		assert.ErrorIs(t, err, ErrSpec)
+	})
+}
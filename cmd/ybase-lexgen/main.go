@@ -0,0 +1,62 @@
+// Command ybase-lexgen generates a lexer for goyacc from a lexer spec.
+//
+// Usage:
+//
+//	ybase-lexgen [-o OUTPUT] SPEC
+//
+// e.g. in the package of the parser:
+//
+//	//go:generate go run github.com/berquerant/ybase/cmd/ybase-lexgen -o lexer.go lexer.l
+//
+// See Spec for the format of the spec.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("ybase-lexgen: ")
+
+	output := flag.String("o", "-", "output file, - means stdout")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: ybase-lexgen [-o OUTPUT] SPEC")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), *output); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(input, output string) error {
+	src, err := os.ReadFile(input)
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(input)
+	spec, err := ParseSpec(name, src)
+	if err != nil {
+		return err
+	}
+	code, err := Generate(spec, name)
+	if err != nil {
+		return err
+	}
+
+	if output == "-" {
+		_, err := os.Stdout.Write(code)
+		return err
+	}
+	return os.WriteFile(output, code, 0o644)
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/berquerant/ybase"
+)
+
+var ErrSpec = errors.New("Spec")
+
+// Spec is a lexer spec.
+//
+// The format is like flex:
+//
+//	// declarations
+//	%package calc
+//	%token NUM IDENT
+//	%state COMMENT
+//	%%
+//	  // rules: [<STATE,...>]PATTERN ACTION
+//	[ \t\n]+        skip
+//	"/*"            { r.PushState(COMMENT) }
+//	<COMMENT>"*/"   { r.PopState() }
+//	<COMMENT>.|\n   skip
+//	[0-9]+          NUM
+//	"+"             '+'
+//	%%
+//	// go code
+//
+// Declarations:
+//
+//	%package NAME    package of the generated file, required
+//	%prefix PREFIX   prefix of goyacc (-p), default yy
+//	%field NAME      field of PREFIXSymType to store ybase.Token, default token
+//	%lexer NAME      name of the generated lexer type, default Lexer
+//	%token NAME...   tokens in the order of the grammar
+//	%extern          the token constants are declared by the parser of goyacc in the same package,
+//	                 the generated file does not declare them
+//	%state NAME...   exclusive start states
+//	%{ ... %}        go code copied verbatim, e.g. imports
+//
+// The lines starting with // are comments in the declarations.
+// In the rules, the comments are indented, e.g. "  // comment", because a pattern may start with //.
+//
+// PATTERN is a regular expression terminated by a space, or a quoted literal.
+// The rule is active in INITIAL if no states are given, in all states if <*>.
+//
+// ACTION is one of:
+//
+//	skip       discards the matched text
+//	NAME       returns the token, a declared token or a character literal
+//	{ CODE }   runs CODE with r (ybase.Reader), e.g. r.PushState(STATE);
+//	           discards the matched text unless CODE returns
+type Spec struct {
+	Package string
+	Prefix  string
+	Field   string
+	Lexer   string
+	Code    string
+	Tokens  []string
+	// Extern is true if the token constants are declared outside the generated file.
+	Extern  bool
+	States  []string
+	Rules   []*Rule
+	Trailer string
+}
+
+// AllStates returns the start states including INITIAL.
+func (s *Spec) AllStates() []string {
+	return append([]string{ybase.InitialState}, s.States...)
+}
+
+// ActionKind is a kind of Action.
+type ActionKind int
+
+const (
+	ActionSkip ActionKind = iota
+	ActionReturn
+	ActionCode
+)
+
+// Action is an action of Rule.
+type Action struct {
+	Kind ActionKind
+	// Value is a token for ActionReturn, a block for ActionCode.
+	Value string
+}
+
+// Rule is a rule of Spec.
+type Rule struct {
+	Line int
+	// States are the start states where the rule is active.
+	States  []string
+	Pattern string
+	Action  Action
+}
+
+var (
+	identRegexp   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	charLitRegexp = regexp.MustCompile(`^'(\\.|[^'\\])+'$`)
+)
+
+// ParseSpec parses a lexer spec.
+func ParseSpec(name string, src []byte) (*Spec, error) {
+	p := &specParser{
+		name: name,
+		spec: &Spec{
+			Prefix: "yy",
+			Field:  "token",
+			Lexer:  "Lexer",
+		},
+	}
+	sc := bufio.NewScanner(bytes.NewReader(src))
+	for sc.Scan() {
+		p.lines = append(p.lines, sc.Text())
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrSpec, name, err)
+	}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	return p.spec, nil
+}
+
+type specParser struct {
+	name  string
+	lines []string
+	i     int
+	spec  *Spec
+}
+
+func (p *specParser) errorf(format string, v ...any) error {
+	return fmt.Errorf("%w: %s:%d: %s", ErrSpec, p.name, p.i+1, fmt.Sprintf(format, v...))
+}
+
+func (p *specParser) parse() error {
+	if err := p.parseDeclarations(); err != nil {
+		return err
+	}
+	if err := p.parseRules(); err != nil {
+		return err
+	}
+	if p.i < len(p.lines) {
+		p.spec.Trailer = strings.Join(p.lines[p.i+1:], "\n")
+	}
+	return p.validate()
+}
+
+func isComment(line string) bool {
+	line = strings.TrimSpace(line)
+	return line == "" || strings.HasPrefix(line, "//")
+}
+
+// isRuleComment reports whether the line in the rules is a comment.
+// A comment is indented, the line starting with // is a rule.
+func isRuleComment(line string) bool {
+	trimmed := strings.TrimLeft(line, " \t")
+	return trimmed == "" || (len(trimmed) < len(line) && isComment(trimmed))
+}
+
+func (p *specParser) parseDeclarations() error {
+	for ; p.i < len(p.lines); p.i++ {
+		line := p.lines[p.i]
+		if isComment(line) {
+			continue
+		}
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "%%":
+			p.i++
+			return nil
+		case "%{":
+			start := p.i + 1
+			for p.i++; p.i < len(p.lines) && strings.TrimSpace(p.lines[p.i]) != "%}"; p.i++ {
+			}
+			if p.i >= len(p.lines) {
+				return p.errorf("unterminated %%{")
+			}
+			p.spec.Code += strings.Join(p.lines[start:p.i], "\n") + "\n"
+		case "%package", "%prefix", "%field", "%lexer":
+			if len(fields) != 2 || !identRegexp.MatchString(fields[1]) {
+				return p.errorf("%s requires an identifier", fields[0])
+			}
+			switch fields[0] {
+			case "%package":
+				p.spec.Package = fields[1]
+			case "%prefix":
+				p.spec.Prefix = fields[1]
+			case "%field":
+				p.spec.Field = fields[1]
+			case "%lexer":
+				p.spec.Lexer = fields[1]
+			}
+		case "%extern":
+			if len(fields) != 1 {
+				return p.errorf("%%extern takes no arguments")
+			}
+			p.spec.Extern = true
+		case "%token", "%state":
+			for _, x := range fields[1:] {
+				if !identRegexp.MatchString(x) {
+					return p.errorf("invalid name %q", x)
+				}
+				if slices.Contains(p.spec.Tokens, x) || slices.Contains(p.spec.AllStates(), x) {
+					return p.errorf("duplicated name %q", x)
+				}
+				if fields[0] == "%token" {
+					p.spec.Tokens = append(p.spec.Tokens, x)
+				} else {
+					p.spec.States = append(p.spec.States, x)
+				}
+			}
+		default:
+			return p.errorf("unknown declaration %q", fields[0])
+		}
+	}
+	return p.errorf("missing %%%%")
+}
+
+func (p *specParser) parseRules() error {
+	for ; p.i < len(p.lines); p.i++ {
+		line := p.lines[p.i]
+		if strings.TrimSpace(line) == "%%" {
+			return nil
+		}
+		if isRuleComment(line) {
+			continue
+		}
+		rule, err := p.parseRule(strings.TrimSpace(line))
+		if err != nil {
+			return err
+		}
+		p.spec.Rules = append(p.spec.Rules, rule)
+	}
+	return nil
+}
+
+func (p *specParser) parseRule(line string) (*Rule, error) {
+	rule := &Rule{
+		Line:   p.i + 1,
+		States: []string{ybase.InitialState},
+	}
+
+	if strings.HasPrefix(line, "<") {
+		end := strings.Index(line, ">")
+		if end < 0 {
+			return nil, p.errorf("unterminated start states")
+		}
+		rule.States = strings.Split(line[1:end], ",")
+		line = line[end+1:]
+	}
+
+	pattern, rest, err := splitPattern(line)
+	if err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	rule.Pattern = pattern
+
+	switch {
+	case rest == "":
+		return nil, p.errorf("missing action")
+	case rest == "skip":
+		rule.Action = Action{Kind: ActionSkip}
+	case strings.HasPrefix(rest, "{"):
+		code, err := p.parseBlock(rest)
+		if err != nil {
+			return nil, err
+		}
+		rule.Action = Action{Kind: ActionCode, Value: code}
+	case identRegexp.MatchString(rest) || charLitRegexp.MatchString(rest):
+		rule.Action = Action{Kind: ActionReturn, Value: rest}
+	default:
+		return nil, p.errorf("invalid action %q", rest)
+	}
+	return rule, nil
+}
+
+// parseBlock reads lines until the braces are balanced.
+func (p *specParser) parseBlock(first string) (string, error) {
+	var (
+		b     strings.Builder
+		depth int
+		line  = first
+	)
+	for {
+		b.WriteString(line)
+		depth += strings.Count(line, "{") - strings.Count(line, "}")
+		if depth <= 0 {
+			return b.String(), nil
+		}
+		b.WriteString("\n")
+		p.i++
+		if p.i >= len(p.lines) {
+			return "", p.errorf("unterminated action")
+		}
+		line = p.lines[p.i]
+	}
+}
+
+// splitPattern splits a rule into the pattern and the rest.
+func splitPattern(line string) (string, string, error) {
+	if strings.HasPrefix(line, `"`) {
+		for i := 1; i < len(line); i++ {
+			switch line[i] {
+			case '\\':
+				i++
+			case '"':
+				lit, err := strconv.Unquote(line[:i+1])
+				if err != nil {
+					return "", "", fmt.Errorf("invalid literal %s: %w", line[:i+1], err)
+				}
+				return regexp.QuoteMeta(lit), strings.TrimSpace(line[i+1:]), nil
+			}
+		}
+		return "", "", errors.New("unterminated literal")
+	}
+
+	var inClass, escaped bool
+	for i, c := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case inClass:
+			inClass = c != ']'
+		case c == '[':
+			inClass = true
+		case c == ' ' || c == '\t':
+			return line[:i], strings.TrimSpace(line[i:]), nil
+		}
+	}
+	return line, "", nil
+}
+
+func (p *specParser) validate() error {
+	s := p.spec
+	if s.Package == "" {
+		return fmt.Errorf("%w: %s: missing %%package", ErrSpec, p.name)
+	}
+	states := s.AllStates()
+	for _, r := range s.Rules {
+		p.i = r.Line - 1
+		for _, x := range r.States {
+			if x != "*" && !slices.Contains(states, x) {
+				return p.errorf("unknown state %q", x)
+			}
+		}
+		if r.Action.Kind == ActionReturn && identRegexp.MatchString(r.Action.Value) && !slices.Contains(s.Tokens, r.Action.Value) {
+			return p.errorf("unknown token %q", r.Action.Value)
+		}
+	}
+	return nil
+}
+
+// Active reports whether the rule is active in the state.
+func (r *Rule) Active(state string) bool {
+	return slices.Contains(r.States, "*") || slices.Contains(r.States, state)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSpec(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		src := `// comment
+%package calc
+%prefix expr
+%field tok
+%lexer Lex
+%{
+import "strings"
+%}
+%token NUM
+%token IDENT
+%state STR
+%%
+[ \t]+      skip
+"\""        { r.PushState(STR) }
+<STR>[^"]+  { return strings.Count(r.Buffer(), "") }
+<STR,INITIAL>"\"" {
+	r.PopState()
+}
+<*>[0-9]+   NUM
+"+"         '+'
+%%
+func f() {}`
+		got, err := ParseSpec("calc.l", []byte(src))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, &Spec{
+			Package: "calc",
+			Prefix:  "expr",
+			Field:   "tok",
+			Lexer:   "Lex",
+			Code:    "import \"strings\"\n",
+			Tokens:  []string{"NUM", "IDENT"},
+			States:  []string{"STR"},
+			Rules: []*Rule{
+				{
+					Line:    13,
+					States:  []string{"INITIAL"},
+					Pattern: `[ \t]+`,
+					Action:  Action{Kind: ActionSkip},
+				},
+				{
+					Line:    14,
+					States:  []string{"INITIAL"},
+					Pattern: `"`,
+					Action:  Action{Kind: ActionCode, Value: "{ r.PushState(STR) }"},
+				},
+				{
+					Line:    15,
+					States:  []string{"STR"},
+					Pattern: `[^"]+`,
+					Action:  Action{Kind: ActionCode, Value: `{ return strings.Count(r.Buffer(), "") }`},
+				},
+				{
+					Line:    16,
+					States:  []string{"STR", "INITIAL"},
+					Pattern: `"`,
+					Action:  Action{Kind: ActionCode, Value: "{\n\tr.PopState()\n}"},
+				},
+				{
+					Line:    19,
+					States:  []string{"*"},
+					Pattern: `[0-9]+`,
+					Action:  Action{Kind: ActionReturn, Value: "NUM"},
+				},
+				{
+					Line:    20,
+					States:  []string{"INITIAL"},
+					Pattern: `\+`,
+					Action:  Action{Kind: ActionReturn, Value: "'+'"},
+				},
+			},
+			Trailer: "func f() {}",
+		}, got)
+	})
+
+	t.Run("comments", func(t *testing.T) {
+		src := "// comment\n%package calc\n%%\n  // comment\n\t// comment\n//[^\\n]*  skip\n\"//\"  skip\n"
+		got, err := ParseSpec("calc.l", []byte(src))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, []*Rule{
+			{
+				Line:    6,
+				States:  []string{"INITIAL"},
+				Pattern: `//[^\n]*`,
+				Action:  Action{Kind: ActionSkip},
+			},
+			{
+				Line:    7,
+				States:  []string{"INITIAL"},
+				Pattern: `//`,
+				Action:  Action{Kind: ActionSkip},
+			},
+		}, got.Rules)
+	})
+
+	t.Run("extern", func(t *testing.T) {
+		got, err := ParseSpec("calc.l", []byte("%package calc\n%token NUM\n%extern\n%%\n"))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.True(t, got.Extern)
+		assert.Equal(t, []string{"NUM"}, got.Tokens)
+	})
+
+	for _, tc := range []struct {
+		title string
+		src   string
+		err   string
+	}{
+		{
+			title: "missing package",
+			src:   "%%\n",
+			err:   "missing %package",
+		},
+		{
+			title: "missing separator",
+			src:   "%package x\n",
+			err:   "x.l:2: missing %%",
+		},
+		{
+			title: "unknown declaration",
+			src:   "%package x\n%union\n%%\n",
+			err:   "x.l:2: unknown declaration",
+		},
+		{
+			title: "extern with arguments",
+			src:   "%package x\n%extern A\n%%\n",
+			err:   "x.l:2: %extern takes no arguments",
+		},
+		{
+			title: "duplicated name",
+			src:   "%package x\n%token A\n%state A\n%%\n",
+			err:   "x.l:3: duplicated name",
+		},
+		{
+			title: "unknown token",
+			src:   "%package x\n%%\na B\n",
+			err:   "x.l:3: unknown token",
+		},
+		{
+			title: "unknown state",
+			src:   "%package x\n%%\n<S>a skip\n",
+			err:   "x.l:3: unknown state",
+		},
+		{
+			title: "missing action",
+			src:   "%package x\n%%\na\n",
+			err:   "x.l:3: missing action",
+		},
+		{
+			title: "unterminated action",
+			src:   "%package x\n%%\na {\n",
+			err:   "x.l:4: unterminated action",
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			_, err := ParseSpec("x.l", []byte(tc.src))
+			assert.ErrorIs(t, err, ErrSpec)
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+}
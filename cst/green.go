@@ -0,0 +1,204 @@
+// Package cst provides a lossless concrete syntax tree built from goyacc reductions.
+//
+// The tree has two layers like red/green trees:
+// Green is an immutable node without the position that can be shared between trees,
+// Node is a view of Green with the parent and the offset.
+//
+// Build the green nodes by Builder in the yacc actions, e.g.
+//
+//	%union {
+//	  node *cst.Green
+//	}
+//	...
+//	expr: expr '+' expr { $$ = builder.Node(EXPR, $1, $2, $3) }
+//
+// with the lexer in the lossless mode, see ybase.WithLossless:
+//
+//	lexer := ybase.NewLexer(scanner, ybase.WithLossless())
+//	yaccLexer := ybase.NewYaccLexer(lexer, func(lval *yySymType, tok ybase.Token) {
+//	  lval.node = builder.Token(tok)
+//	})
+//	yyParse(yaccLexer)
+//	// program is the value of the start rule
+//	root, err := builder.Finish(program, lexer.EOFTrivia())
+package cst
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/berquerant/ybase"
+)
+
+var ErrLostToken = errors.New("LostToken")
+
+// Green is an immutable node of the tree.
+//
+// Green is a token if it has no children and is made by NewGreenToken,
+// the kind of a token is the token type.
+type Green struct {
+	kind     int
+	isToken  bool
+	leading  string
+	value    string
+	trailing string
+	children []*Green
+	// width is the number of the bytes of the text including the trivia.
+	width int
+}
+
+// NewGreenToken returns a new token.
+func NewGreenToken(kind int, leading, value, trailing string) *Green {
+	return &Green{
+		kind:     kind,
+		isToken:  true,
+		leading:  leading,
+		value:    value,
+		trailing: trailing,
+		width:    len(leading) + len(value) + len(trailing),
+	}
+}
+
+// NewGreenNode returns a new node that has the children.
+// The nil children are ignored, e.g. the values of the empty rules.
+func NewGreenNode(kind int, children ...*Green) *Green {
+	g := &Green{
+		kind:     kind,
+		children: make([]*Green, 0, len(children)),
+	}
+	for _, c := range children {
+		if c == nil {
+			continue
+		}
+		g.children = append(g.children, c)
+		g.width += c.width
+	}
+	return g
+}
+
+func (g *Green) Kind() int     { return g.kind }
+func (g *Green) IsToken() bool { return g.isToken }
+
+// Width returns the number of the bytes of the text including the trivia.
+func (g *Green) Width() int { return g.width }
+
+// Children returns the children, do not modify them.
+func (g *Green) Children() []*Green { return g.children }
+
+// Value returns the value of the token, empty if the node is not a token.
+func (g *Green) Value() string          { return g.value }
+func (g *Green) LeadingTrivia() string  { return g.leading }
+func (g *Green) TrailingTrivia() string { return g.trailing }
+
+// Text returns the text including the trivia.
+func (g *Green) Text() string {
+	var b strings.Builder
+	b.Grow(g.width)
+	g.write(&b)
+	return b.String()
+}
+
+func (g *Green) write(b *strings.Builder) {
+	if g.isToken {
+		b.WriteString(g.leading)
+		b.WriteString(g.value)
+		b.WriteString(g.trailing)
+		return
+	}
+	for _, c := range g.children {
+		c.write(b)
+	}
+}
+
+type greenTokenKey struct {
+	kind                     int
+	leading, value, trailing string
+}
+
+// Builder builds the green nodes from the tokens and the reductions.
+//
+// Builder shares the identical tokens.
+type Builder struct {
+	tokens map[greenTokenKey]*Green
+	// text is the text of all the tokens given to Token.
+	text strings.Builder
+	// base is the offset of the first token including the leading trivia.
+	base    int
+	started bool
+}
+
+func NewBuilder() *Builder {
+	return &Builder{
+		tokens: map[greenTokenKey]*Green{},
+	}
+}
+
+// Token returns the green token of the token.
+//
+// Builder records the tokens to check that the tree has all the tokens, see Finish.
+// Pass all the tokens from the lexer, e.g. by the set function of ybase.NewYaccLexer.
+func (b *Builder) Token(tok ybase.Token) *Green {
+	key := greenTokenKey{
+		kind:     tok.Type(),
+		leading:  tok.LeadingTrivia(),
+		value:    tok.Value(),
+		trailing: tok.TrailingTrivia(),
+	}
+	if !b.started {
+		b.started = true
+		b.base = max(tok.Start().Offset()-len(key.leading), 0)
+	}
+	b.text.WriteString(key.leading)
+	b.text.WriteString(key.value)
+	b.text.WriteString(key.trailing)
+	if g, ok := b.tokens[key]; ok {
+		return g
+	}
+	g := NewGreenToken(key.kind, key.leading, key.value, key.trailing)
+	b.tokens[key] = g
+	return g
+}
+
+// Node returns a new node that has the children, see NewGreenNode.
+func (b *Builder) Node(kind int, children ...*Green) *Green {
+	return NewGreenNode(kind, children...)
+}
+
+// Finish returns the root of the tree.
+//
+// Finish appends the token of ybase.EOF that has eofTrivia as the leading trivia to the root,
+// so that the text of the root is the input.
+// A nil or token root is wrapped in a node of kind 0.
+// The offsets of the tree are the offsets of the tokens.
+//
+// Returns ErrLostToken if the root does not have all the tokens given to Token,
+// e.g. a yacc action ignores the value of a token.
+func (b *Builder) Finish(root *Green, eofTrivia string) (*Node, error) {
+	if root == nil {
+		root = NewGreenNode(0)
+	}
+	if root.isToken {
+		root = NewGreenNode(0, root)
+	}
+	children := append(slices.Clone(root.children), NewGreenToken(ybase.EOF, eofTrivia, "", ""))
+	root = NewGreenNode(root.kind, children...)
+
+	want := b.text.String() + eofTrivia
+	if got := root.Text(); got != want {
+		return nil, fmt.Errorf("%w: the tree differs from the tokens at offset %d",
+			ErrLostToken, b.base+commonPrefix(got, want))
+	}
+	return newRoot(root, b.base), nil
+}
+
+func commonPrefix(a, b string) int {
+	n := min(len(a), len(b))
+	for i := range n {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
@@ -0,0 +1,179 @@
+package cst_test
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+	"unicode"
+
+	"github.com/berquerant/ybase"
+	"github.com/berquerant/ybase/cst"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	NUM = iota + ybase.FirstYaccToken
+	PLUS
+	EXPR
+	PROGRAM
+)
+
+var registry = ybase.NewRegistry().
+	Register(NUM, "NUM", ybase.CategoryLiteral).
+	Register(PLUS, "PLUS", ybase.CategoryOperator).
+	Register(EXPR, "EXPR", ybase.CategoryUnknown).
+	Register(PROGRAM, "PROGRAM", ybase.CategoryUnknown).
+	Register(ybase.EOF, "EOF", ybase.CategoryUnknown)
+
+type symType struct {
+	node *cst.Green
+}
+
+// parse builds the tree like the yacc actions of
+//
+//	program: expr       { $$ = builder.Node(PROGRAM, $1) }
+//	expr: NUM           { $$ = builder.Node(EXPR, $1) }
+//	    | expr PLUS NUM { $$ = builder.Node(EXPR, $1, $2, $3) }
+//
+// skip drops the i-th token from the tree if not negative.
+func parse(input string, skip int) (*cst.Node, error) {
+	builder := cst.NewBuilder()
+	lexer := ybase.NewLexer(ybase.NewScanner(
+		ybase.NewReader(bytes.NewBufferString(input), nil),
+		ybase.NewRuleScanFunc(
+			ybase.Rule{Pattern: ybase.Runes(unicode.IsSpace), Skip: true},
+			ybase.Rule{Pattern: ybase.Regexp(regexp.MustCompile(`#[^\n]*`)), Skip: true},
+			ybase.Rule{Type: NUM, Pattern: ybase.Runes(unicode.IsDigit)},
+			ybase.Rule{Type: PLUS, Pattern: ybase.Literal("+")},
+		),
+	), ybase.WithLossless())
+	yaccLexer := ybase.NewYaccLexer(lexer, func(lval *symType, tok ybase.Token) {
+		lval.node = builder.Token(tok)
+	})
+
+	var (
+		lval     symType
+		expr, op *cst.Green
+	)
+	for i := 0; ; i++ {
+		t := yaccLexer.Lex(&lval)
+		if t == ybase.EOF {
+			break
+		}
+		x := lval.node
+		if i == skip {
+			x = nil
+		}
+		switch {
+		case t == PLUS:
+			op = x
+		case expr == nil:
+			expr = builder.Node(EXPR, x)
+		default:
+			expr = builder.Node(EXPR, expr, op, x)
+		}
+	}
+	if err := yaccLexer.Err(); err != nil {
+		return nil, err
+	}
+	return builder.Finish(builder.Node(PROGRAM, expr), lexer.EOFTrivia())
+}
+
+func TestBuilder(t *testing.T) {
+	for _, tc := range []struct {
+		title string
+		input string
+		skip  int
+		want  string
+		err   error
+	}{
+		{
+			title: "empty",
+			input: "",
+			skip:  -1,
+			want: `PROGRAM@0..0
+  EOF@0..0 "" "" ""
+`,
+		},
+		{
+			title: "trivia",
+			input: "# head\n1 + 2 # two\n  + 3\n# end\n",
+			skip:  -1,
+			want: `PROGRAM@0..31
+  EXPR@0..25
+    EXPR@0..19
+      EXPR@0..9
+        NUM@0..9 "# head\n" "1" " "
+      PLUS@9..11 "" "+" " "
+      NUM@11..19 "" "2" " # two\n"
+    PLUS@19..23 "  " "+" " "
+    NUM@23..25 "" "3" "\n"
+  EOF@25..31 "# end\n" "" ""
+`,
+		},
+		{
+			title: "lost token",
+			input: "1 + 2",
+			skip:  1,
+			err:   cst.ErrLostToken,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			root, err := parse(tc.input, tc.skip)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				assert.ErrorContains(t, err, "offset 2")
+				return
+			}
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, tc.want, root.Dump(registry.Name))
+			assert.Equal(t, tc.input, root.FullText())
+		})
+	}
+
+	t.Run("share tokens", func(t *testing.T) {
+		root, err := parse("1 + 1 + 1", -1)
+		if !assert.Nil(t, err) {
+			return
+		}
+		var plus []*cst.Green
+		for x := range root.Tokens() {
+			if x.Kind() == PLUS {
+				plus = append(plus, x.Green())
+			}
+		}
+		if assert.Len(t, plus, 2) {
+			assert.Same(t, plus[0], plus[1])
+		}
+	})
+
+	t.Run("offset of the reader", func(t *testing.T) {
+		builder := cst.NewBuilder()
+		start := ybase.NewPos(1, 10, 10)
+		tok := builder.Token(ybase.NewToken(NUM, "1", start, start.Add('1')))
+		root, err := builder.Finish(tok, "")
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, cst.Range{Start: 10, End: 11}, root.Range())
+		assert.Equal(t, 0, root.Kind())
+	})
+}
+
+func TestGreen(t *testing.T) {
+	g := cst.NewGreenNode(EXPR,
+		cst.NewGreenToken(NUM, " ", "1", ""),
+		nil,
+		cst.NewGreenNode(EXPR),
+		cst.NewGreenToken(PLUS, "", "+", "\n"),
+	)
+	assert.False(t, g.IsToken())
+	assert.Equal(t, 3, len(g.Children()))
+	assert.Equal(t, 4, g.Width())
+	assert.Equal(t, " 1+\n", g.Text())
+	assert.Equal(t, "1", g.Children()[0].Value())
+	assert.True(t, g.Children()[0].IsToken())
+	assert.Equal(t, "\n", g.Children()[2].TrailingTrivia())
+}
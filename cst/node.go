@@ -0,0 +1,298 @@
+package cst
+
+import (
+	"fmt"
+	"iter"
+	"strconv"
+	"strings"
+)
+
+// Range is a range of the offsets from Start to End, End is exclusive.
+type Range struct {
+	Start int
+	End   int
+}
+
+func (r Range) Len() int { return r.End - r.Start }
+
+// ContainsOffset reports whether the offset is in the range.
+func (r Range) ContainsOffset(offset int) bool {
+	return r.Start <= offset && offset < r.End
+}
+
+// Covers reports whether the range includes the other.
+func (r Range) Covers(other Range) bool {
+	return r.Start <= other.Start && other.End <= r.End
+}
+
+func (r Range) String() string { return fmt.Sprintf("%d..%d", r.Start, r.End) }
+
+// Node is a view of Green in the tree.
+//
+// Node knows the parent and the offset.
+// The navigation methods return new Nodes, compare Green and Offset to identify the nodes.
+type Node struct {
+	green  *Green
+	parent *Node
+	// index is the index in the parent.
+	index int
+	// offset is the offset of the text including the trivia.
+	offset int
+}
+
+// NewRoot returns the root of the tree at offset 0.
+func NewRoot(g *Green) *Node { return newRoot(g, 0) }
+
+func newRoot(g *Green, offset int) *Node {
+	return &Node{
+		green:  g,
+		offset: offset,
+	}
+}
+
+func (n *Node) Green() *Green          { return n.green }
+func (n *Node) Kind() int              { return n.green.kind }
+func (n *Node) IsToken() bool          { return n.green.isToken }
+func (n *Node) Value() string          { return n.green.value }
+func (n *Node) LeadingTrivia() string  { return n.green.leading }
+func (n *Node) TrailingTrivia() string { return n.green.trailing }
+
+// Parent returns the parent, nil if the node is the root.
+func (n *Node) Parent() *Node { return n.parent }
+
+// Index returns the index in the parent.
+func (n *Node) Index() int { return n.index }
+
+// Offset returns the offset of the text including the trivia.
+func (n *Node) Offset() int { return n.offset }
+
+// FullRange returns the range of the text including the trivia.
+func (n *Node) FullRange() Range {
+	return Range{
+		Start: n.offset,
+		End:   n.offset + n.green.width,
+	}
+}
+
+// Range returns the range of the text without the leading trivia of the first token
+// and the trailing trivia of the last token.
+func (n *Node) Range() Range {
+	r := n.FullRange()
+	if first := n.FirstToken(); first != nil {
+		r.Start = first.offset + len(first.green.leading)
+	}
+	if last := n.LastToken(); last != nil {
+		r.End = last.offset + last.green.width - len(last.green.trailing)
+	}
+	return r
+}
+
+// FullText returns the text including the trivia.
+func (n *Node) FullText() string { return n.green.Text() }
+
+// Text returns the text of Range.
+func (n *Node) Text() string {
+	r := n.Range()
+	return n.FullText()[r.Start-n.offset : r.End-n.offset]
+}
+
+func (n *Node) NumChildren() int { return len(n.green.children) }
+
+// Child returns the i-th child, nil if out of range.
+func (n *Node) Child(i int) *Node {
+	if i < 0 || i >= len(n.green.children) {
+		return nil
+	}
+	offset := n.offset
+	for _, c := range n.green.children[:i] {
+		offset += c.width
+	}
+	return n.child(i, offset)
+}
+
+func (n *Node) child(i, offset int) *Node {
+	return &Node{
+		green:  n.green.children[i],
+		parent: n,
+		index:  i,
+		offset: offset,
+	}
+}
+
+// Children returns an iterator over the children.
+func (n *Node) Children() iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		offset := n.offset
+		for i, c := range n.green.children {
+			if !yield(n.child(i, offset)) {
+				return
+			}
+			offset += c.width
+		}
+	}
+}
+
+// NextSibling returns the next child of the parent, nil if none.
+func (n *Node) NextSibling() *Node {
+	if n.parent == nil || n.index+1 >= len(n.parent.green.children) {
+		return nil
+	}
+	return n.parent.child(n.index+1, n.offset+n.green.width)
+}
+
+// PrevSibling returns the previous child of the parent, nil if none.
+func (n *Node) PrevSibling() *Node {
+	if n.parent == nil || n.index == 0 {
+		return nil
+	}
+	return n.parent.child(n.index-1, n.offset-n.parent.green.children[n.index-1].width)
+}
+
+// Ancestors returns an iterator over the parent, the parent of the parent and so on.
+func (n *Node) Ancestors() iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		for x := n.parent; x != nil; x = x.parent {
+			if !yield(x) {
+				return
+			}
+		}
+	}
+}
+
+// Preorder returns an iterator over the node and the descendants in preorder.
+func (n *Node) Preorder() iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		n.preorder(yield)
+	}
+}
+
+func (n *Node) preorder(yield func(*Node) bool) bool {
+	if !yield(n) {
+		return false
+	}
+	for c := range n.Children() {
+		if !c.preorder(yield) {
+			return false
+		}
+	}
+	return true
+}
+
+// Tokens returns an iterator over the tokens in the node.
+func (n *Node) Tokens() iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		for x := range n.Preorder() {
+			if x.IsToken() && !yield(x) {
+				return
+			}
+		}
+	}
+}
+
+// FirstToken returns the first token in the node, nil if none.
+func (n *Node) FirstToken() *Node {
+	for x := range n.Tokens() {
+		return x
+	}
+	return nil
+}
+
+// LastToken returns the last token in the node, nil if none.
+func (n *Node) LastToken() *Node {
+	x := n
+	for !x.IsToken() {
+		i := len(x.green.children) - 1
+		for i >= 0 && !hasToken(x.green.children[i]) {
+			i--
+		}
+		if i < 0 {
+			return nil
+		}
+		x = x.Child(i)
+	}
+	return x
+}
+
+func hasToken(g *Green) bool {
+	if g.isToken {
+		return true
+	}
+	for _, c := range g.children {
+		if hasToken(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenAt returns the token whose full range contains the offset, nil if none.
+//
+// The zero-width tokens are not found.
+func (n *Node) TokenAt(offset int) *Node {
+	if !n.FullRange().ContainsOffset(offset) {
+		return nil
+	}
+	x := n
+	for !x.IsToken() {
+		var next *Node
+		for c := range x.Children() {
+			if c.FullRange().ContainsOffset(offset) {
+				next = c
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		x = next
+	}
+	return x
+}
+
+// Covering returns the deepest node whose range covers r, nil if the node does not.
+func (n *Node) Covering(r Range) *Node {
+	if !n.Range().Covers(r) {
+		return nil
+	}
+	x := n
+	for {
+		var next *Node
+		for c := range x.Children() {
+			if c.Range().Covers(r) && c.green.width > 0 {
+				next = c
+				break
+			}
+		}
+		if next == nil {
+			return x
+		}
+		x = next
+	}
+}
+
+// Dump returns the tree as indented lines:
+//
+//	KIND@START..END
+//	  KIND@START..END "LEADING" "VALUE" "TRAILING"
+//
+// The ranges are the full ranges.
+// name names the kinds, strconv.Itoa if nil.
+func (n *Node) Dump(name func(int) string) string {
+	if name == nil {
+		name = strconv.Itoa
+	}
+	var b strings.Builder
+	n.dump(&b, name, 0)
+	return b.String()
+}
+
+func (n *Node) dump(b *strings.Builder, name func(int) string, depth int) {
+	fmt.Fprintf(b, "%s%s@%s", strings.Repeat("  ", depth), name(n.Kind()), n.FullRange())
+	if n.IsToken() {
+		fmt.Fprintf(b, " %q %q %q", n.LeadingTrivia(), n.Value(), n.TrailingTrivia())
+	}
+	b.WriteByte('\n')
+	for c := range n.Children() {
+		c.dump(b, name, depth+1)
+	}
+}
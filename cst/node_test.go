@@ -0,0 +1,133 @@
+package cst_test
+
+import (
+	"testing"
+
+	"github.com/berquerant/ybase/cst"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNode(t *testing.T) {
+	// offsets: "# c\n" 0..4, "1 + 2 # two\n" 4..16, "+ 3\n" 16..20
+	const input = "# c\n1 + 2 # two\n+ 3\n"
+	root, err := parse(input, -1)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	t.Run("range", func(t *testing.T) {
+		assert.Equal(t, cst.Range{Start: 0, End: 20}, root.FullRange())
+		assert.Equal(t, cst.Range{Start: 4, End: 20}, root.Range())
+
+		expr := root.Child(0)
+		assert.Equal(t, EXPR, expr.Kind())
+		assert.Equal(t, cst.Range{Start: 0, End: 20}, expr.FullRange())
+		assert.Equal(t, cst.Range{Start: 4, End: 19}, expr.Range())
+		assert.Equal(t, "1 + 2 # two\n+ 3", expr.Text())
+
+		inner := expr.Child(0)
+		assert.Equal(t, "1 + 2", inner.Text())
+		assert.Equal(t, "# c\n1 + 2 # two\n", inner.FullText())
+	})
+
+	t.Run("navigation", func(t *testing.T) {
+		expr := root.Child(0)
+		assert.Nil(t, root.Parent())
+		assert.Nil(t, root.Child(2))
+		assert.Equal(t, 2, root.NumChildren())
+		assert.Equal(t, 3, expr.NumChildren())
+
+		plus := expr.Child(1)
+		assert.Equal(t, PLUS, plus.Kind())
+		assert.Equal(t, 1, plus.Index())
+		assert.Equal(t, 16, plus.Offset())
+		assert.Same(t, expr.Green(), plus.Parent().Green())
+
+		next := plus.NextSibling()
+		assert.Equal(t, "3", next.Value())
+		assert.Equal(t, 18, next.Offset())
+		assert.Nil(t, next.NextSibling())
+
+		prev := plus.PrevSibling()
+		assert.Equal(t, EXPR, prev.Kind())
+		assert.Equal(t, 0, prev.Offset())
+		assert.Nil(t, prev.PrevSibling())
+
+		var kinds []int
+		for x := range next.Ancestors() {
+			kinds = append(kinds, x.Kind())
+		}
+		assert.Equal(t, []int{EXPR, PROGRAM}, kinds)
+	})
+
+	t.Run("tokens", func(t *testing.T) {
+		var got []string
+		for x := range root.Tokens() {
+			got = append(got, x.Value())
+		}
+		assert.Equal(t, []string{"1", "+", "2", "+", "3", ""}, got)
+		assert.Equal(t, "1", root.FirstToken().Value())
+		assert.Equal(t, "3", root.Child(0).LastToken().Value())
+		assert.Nil(t, cst.NewRoot(cst.NewGreenNode(EXPR)).FirstToken())
+		assert.Nil(t, cst.NewRoot(cst.NewGreenNode(EXPR)).LastToken())
+
+		var n int
+		for range root.Preorder() {
+			n++
+		}
+		// PROGRAM EXPR EXPR EXPR NUM PLUS NUM PLUS NUM EOF
+		assert.Equal(t, 10, n)
+	})
+
+	t.Run("TokenAt", func(t *testing.T) {
+		for _, tc := range []struct {
+			offset int
+			want   string
+		}{
+			{offset: 0, want: "1"},
+			{offset: 4, want: "1"},
+			{offset: 5, want: "1"},
+			{offset: 6, want: "+"},
+			{offset: 10, want: "2"},
+			{offset: 18, want: "3"},
+			{offset: 19, want: "3"},
+		} {
+			if x := root.TokenAt(tc.offset); assert.NotNil(t, x, tc.offset) {
+				assert.Equal(t, tc.want, x.Value(), tc.offset)
+			}
+		}
+		assert.Nil(t, root.TokenAt(-1))
+		assert.Nil(t, root.TokenAt(20))
+	})
+
+	t.Run("Covering", func(t *testing.T) {
+		for _, tc := range []struct {
+			title string
+			r     cst.Range
+			want  string
+		}{
+			{title: "token", r: cst.Range{Start: 8, End: 9}, want: "2"},
+			{title: "empty", r: cst.Range{Start: 6, End: 6}, want: "+"},
+			{title: "inner", r: cst.Range{Start: 4, End: 9}, want: "1 + 2"},
+			{title: "across", r: cst.Range{Start: 8, End: 17}, want: "1 + 2 # two\n+ 3"},
+			{title: "trivia", r: cst.Range{Start: 10, End: 12}, want: "1 + 2 # two\n+ 3"},
+		} {
+			t.Run(tc.title, func(t *testing.T) {
+				if x := root.Covering(tc.r); assert.NotNil(t, x) {
+					assert.Equal(t, tc.want, x.Text())
+				}
+			})
+		}
+		assert.Nil(t, root.Child(0).Covering(cst.Range{Start: 0, End: 1}))
+	})
+}
+
+func TestRange(t *testing.T) {
+	r := cst.Range{Start: 2, End: 5}
+	assert.Equal(t, 3, r.Len())
+	assert.Equal(t, "2..5", r.String())
+	assert.True(t, r.ContainsOffset(2))
+	assert.False(t, r.ContainsOffset(5))
+	assert.True(t, r.Covers(cst.Range{Start: 5, End: 5}))
+	assert.False(t, r.Covers(cst.Range{Start: 1, End: 3}))
+}
@@ -0,0 +1,364 @@
+package ybase
+
+import (
+	"errors"
+	"fmt"
+	"regexp/syntax"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+var ErrUnsupportedPattern = errors.New("UnsupportedPattern")
+
+// RegexpRule is a pair of a regular expression and a token type for CompileDFA.
+type RegexpRule struct {
+	Type int
+	// Pattern is a regular expression in the syntax of regexp.
+	// Empty-width assertions like ^, $ and \b are not supported.
+	Pattern string
+	// Skip discards the matched runes instead of returning a token, e.g. whitespaces and comments.
+	Skip bool
+}
+
+// DFATransition is a transition on the runes from Lo to Hi inclusive.
+type DFATransition struct {
+	Lo, Hi rune
+	Next   int
+}
+
+// DFAState is a state of DFA.
+type DFAState struct {
+	// Accept is the index of the accepted rule, -1 if the state does not accept.
+	Accept int
+	// Transitions are sorted by Lo and disjoint.
+	Transitions []DFATransition
+}
+
+// next returns the next state on x, -1 if no transition.
+func (s DFAState) next(x rune) int {
+	ts := s.Transitions
+	i := sort.Search(len(ts), func(i int) bool { return ts[i].Hi >= x })
+	if i < len(ts) && ts[i].Lo <= x {
+		return ts[i].Next
+	}
+	return -1
+}
+
+// DFA is a minimized deterministic finite automaton that matches the rules at once.
+// The start state is States[0].
+type DFA struct {
+	Rules  []RegexpRule
+	States []DFAState
+}
+
+// CompileDFA compiles the rules into a DFA.
+//
+// The longest match wins, and the first rule wins among the matches of the same length,
+// same as NewRuleScanFunc.
+func CompileDFA(rules ...RegexpRule) (*DFA, error) {
+	n, err := newNFA(rules)
+	if err != nil {
+		return nil, err
+	}
+	return &DFA{
+		Rules:  rules,
+		States: minimizeDFA(n.determinize()),
+	}, nil
+}
+
+// Match returns the index of the rule of the longest match and the number of the matched runes
+// without consuming them.
+// Returns -1 if not matched.
+func (d *DFA) Match(r Reader) (int, int) {
+	a := newLookahead(r)
+	index, size := -1, 0
+	state := 0
+	for i := 0; ; i++ {
+		x, ok := a.at(i)
+		if !ok {
+			break
+		}
+		if state = d.States[state].next(x); state < 0 {
+			break
+		}
+		if accept := d.States[state].Accept; accept >= 0 {
+			index, size = accept, i+1
+		}
+	}
+	return index, size
+}
+
+// ScanFunc returns a ScanFunc that scans the input by the DFA.
+// Sets ErrNoRuleMatched if no rule matches.
+func (d *DFA) ScanFunc() ScanFunc {
+	return func(r Reader) int {
+		return scanMatch(r, d.Match, func(index int) (int, bool) {
+			return d.Rules[index].Type, d.Rules[index].Skip
+		})
+	}
+}
+
+// nfa is a union of the programs of the rules.
+// A state is identified by the rule and the pc, numbered by base[rule] + pc.
+type nfa struct {
+	progs []*syntax.Prog
+	base  []int
+}
+
+func newNFA(rules []RegexpRule) (*nfa, error) {
+	n := &nfa{
+		progs: make([]*syntax.Prog, len(rules)),
+		base:  make([]int, len(rules)),
+	}
+	var size int
+	for i, rule := range rules {
+		re, err := syntax.Parse(rule.Pattern, syntax.Perl)
+		if err != nil {
+			return nil, fmt.Errorf("%w: rule %d: %w", ErrYbase, i, err)
+		}
+		prog, err := syntax.Compile(re.Simplify())
+		if err != nil {
+			return nil, fmt.Errorf("%w: rule %d: %w", ErrYbase, i, err)
+		}
+		for _, inst := range prog.Inst {
+			if inst.Op == syntax.InstEmptyWidth {
+				return nil, fmt.Errorf("%w: %w: rule %d: empty-width assertion in %q",
+					ErrYbase, ErrUnsupportedPattern, i, rule.Pattern)
+			}
+		}
+		n.progs[i] = prog
+		n.base[i] = size
+		size += len(prog.Inst)
+	}
+	return n, nil
+}
+
+func (n *nfa) inst(id int) (int, *syntax.Inst) {
+	rule := sort.Search(len(n.base), func(i int) bool { return n.base[i] > id }) - 1
+	return rule, &n.progs[rule].Inst[id-n.base[rule]]
+}
+
+// closure returns the sorted states that consume a rune or match, reachable from ids without consuming.
+func (n *nfa) closure(ids []int) []int {
+	var (
+		seen   = map[int]bool{}
+		result []int
+		stack  = slices.Clone(ids)
+	)
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		rule, inst := n.inst(id)
+		base := n.base[rule]
+		switch inst.Op {
+		case syntax.InstAlt, syntax.InstAltMatch:
+			stack = append(stack, base+int(inst.Out), base+int(inst.Arg))
+		case syntax.InstCapture, syntax.InstNop:
+			stack = append(stack, base+int(inst.Out))
+		case syntax.InstMatch, syntax.InstRune, syntax.InstRune1, syntax.InstRuneAny, syntax.InstRuneAnyNotNL:
+			result = append(result, id)
+		}
+	}
+	slices.Sort(result)
+	return result
+}
+
+// accept returns the first rule that matches in the states, -1 if none.
+func (n *nfa) accept(ids []int) int {
+	for _, id := range ids {
+		if rule, inst := n.inst(id); inst.Op == syntax.InstMatch {
+			return rule
+		}
+	}
+	return -1
+}
+
+// runeRanges returns the ranges of the runes consumed by the state as pairs of lo and hi.
+func (n *nfa) runeRanges(id int) []rune {
+	_, inst := n.inst(id)
+	switch inst.Op {
+	case syntax.InstRune:
+		if len(inst.Rune) == 1 {
+			x := inst.Rune[0]
+			rs := []rune{x, x}
+			if syntax.Flags(inst.Arg)&syntax.FoldCase != 0 {
+				for y := unicode.SimpleFold(x); y != x; y = unicode.SimpleFold(y) {
+					rs = append(rs, y, y)
+				}
+			}
+			return rs
+		}
+		return inst.Rune
+	case syntax.InstRune1:
+		return []rune{inst.Rune[0], inst.Rune[0]}
+	case syntax.InstRuneAny:
+		return []rune{0, unicode.MaxRune}
+	case syntax.InstRuneAnyNotNL:
+		return []rune{0, '\n' - 1, '\n' + 1, unicode.MaxRune}
+	default:
+		return nil
+	}
+}
+
+// determinize builds a DFA by the subset construction.
+func (n *nfa) determinize() []DFAState {
+	var (
+		states []DFAState
+		sets   [][]int
+		index  = map[string]int{}
+	)
+	add := func(ids []int) int {
+		key := fmt.Sprint(ids)
+		if i, ok := index[key]; ok {
+			return i
+		}
+		i := len(states)
+		index[key] = i
+		states = append(states, DFAState{
+			Accept: n.accept(ids),
+		})
+		sets = append(sets, ids)
+		return i
+	}
+
+	starts := make([]int, len(n.progs))
+	for i, prog := range n.progs {
+		starts[i] = n.base[i] + prog.Start
+	}
+	add(n.closure(starts))
+
+	for i := 0; i < len(states); i++ {
+		// split the runes into the intervals [points[k], points[k+1])
+		var points []rune
+		for _, id := range sets[i] {
+			rs := n.runeRanges(id)
+			for j := 0; j < len(rs); j += 2 {
+				points = append(points, rs[j], rs[j+1]+1)
+			}
+		}
+		slices.Sort(points)
+		points = slices.Compact(points)
+		if len(points) == 0 {
+			continue
+		}
+
+		targets := make([][]int, len(points)-1)
+		for _, id := range sets[i] {
+			rule, inst := n.inst(id)
+			out := n.base[rule] + int(inst.Out)
+			rs := n.runeRanges(id)
+			for j := 0; j < len(rs); j += 2 {
+				k, _ := slices.BinarySearch(points, rs[j])
+				for ; k < len(targets) && points[k] <= rs[j+1]; k++ {
+					targets[k] = append(targets[k], out)
+				}
+			}
+		}
+
+		var transitions []DFATransition
+		for k, target := range targets {
+			if len(target) == 0 {
+				continue
+			}
+			next := add(n.closure(target))
+			lo, hi := points[k], points[k+1]-1
+			if last := len(transitions) - 1; last >= 0 && transitions[last].Next == next && transitions[last].Hi+1 == lo {
+				transitions[last].Hi = hi
+				continue
+			}
+			transitions = append(transitions, DFATransition{
+				Lo:   lo,
+				Hi:   hi,
+				Next: next,
+			})
+		}
+		states[i].Transitions = transitions
+	}
+	return states
+}
+
+// minimizeDFA merges the equivalent states by the partition refinement.
+func minimizeDFA(states []DFAState) []DFAState {
+	class := make([]int, len(states))
+	for i, s := range states {
+		class[i] = s.Accept
+	}
+
+	// transitions returns the transitions of the state to the classes.
+	transitions := func(s DFAState) []DFATransition {
+		var ts []DFATransition
+		for _, t := range s.Transitions {
+			next := class[t.Next]
+			if last := len(ts) - 1; last >= 0 && ts[last].Next == next && ts[last].Hi+1 == t.Lo {
+				ts[last].Hi = t.Hi
+				continue
+			}
+			ts = append(ts, DFATransition{
+				Lo:   t.Lo,
+				Hi:   t.Hi,
+				Next: next,
+			})
+		}
+		return ts
+	}
+
+	for count := -1; ; {
+		var (
+			index = map[string]int{}
+			next  = make([]int, len(states))
+		)
+		for i, s := range states {
+			var b strings.Builder
+			fmt.Fprint(&b, class[i])
+			for _, t := range transitions(s) {
+				fmt.Fprintf(&b, ",%d-%d:%d", t.Lo, t.Hi, t.Next)
+			}
+			key := b.String()
+			c, ok := index[key]
+			if !ok {
+				c = len(index)
+				index[key] = c
+			}
+			next[i] = c
+		}
+		class = next
+		if len(index) == count {
+			break
+		}
+		count = len(index)
+	}
+
+	// renumber the classes in the order of the first appearance so that the start state is 0
+	number := map[int]int{}
+	for _, c := range class {
+		if _, ok := number[c]; !ok {
+			number[c] = len(number)
+		}
+	}
+	var (
+		result = make([]DFAState, len(number))
+		done   = make([]bool, len(number))
+	)
+	for i, s := range states {
+		c := number[class[i]]
+		if done[c] {
+			continue
+		}
+		done[c] = true
+		ts := transitions(s)
+		for j := range ts {
+			ts[j].Next = number[ts[j].Next]
+		}
+		result[c] = DFAState{
+			Accept:      s.Accept,
+			Transitions: ts,
+		}
+	}
+	return result
+}
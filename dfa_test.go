@@ -0,0 +1,245 @@
+package ybase_test
+
+import (
+	"bytes"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/berquerant/ybase"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	dfaIdent = iota + 1
+	dfaNum
+	dfaKeyword
+	dfaOp
+	dfaAssign
+	dfaHan
+)
+
+var dfaRules = []ybase.RegexpRule{
+	{Pattern: `[ \t\n]+`, Skip: true},
+	{Pattern: `//.*`, Skip: true},
+	{Type: dfaKeyword, Pattern: `(?i)select|from`},
+	{Type: dfaIdent, Pattern: `[a-zA-Z_][a-zA-Z0-9_]*`},
+	{Type: dfaNum, Pattern: `[0-9]+(\.[0-9]+)?`},
+	{Type: dfaOp, Pattern: `[-+*/()]|<<|<<=|<`},
+	{Type: dfaAssign, Pattern: `=|<<=`},
+	{Type: dfaHan, Pattern: `\p{Han}+`},
+}
+
+func lexAll(t testing.TB, input string, scan ybase.ScanFunc) ([]ybase.Token, error) {
+	t.Helper()
+	s := ybase.NewLexer(ybase.NewScanner(ybase.NewReader(bytes.NewBufferString(input), nil), scan))
+	got := []ybase.Token{}
+	for s.DoLex(func(tok ybase.Token) { got = append(got, tok) }) != ybase.EOF {
+	}
+	return got, s.Err()
+}
+
+// newRuleScanFunc returns the ScanFunc of NewRuleScanFunc equivalent to the DFA of dfaRules.
+func newRuleScanFunc() ybase.ScanFunc {
+	rules := make([]ybase.Rule, len(dfaRules))
+	for i, r := range dfaRules {
+		rules[i] = ybase.Rule{
+			Type:    r.Type,
+			Pattern: ybase.Regexp(regexp.MustCompile(r.Pattern)),
+			Skip:    r.Skip,
+		}
+	}
+	return ybase.NewRuleScanFunc(rules...)
+}
+
+func TestDFA(t *testing.T) {
+	d, err := ybase.CompileDFA(dfaRules...)
+	if !assert.Nil(t, err) {
+		return
+	}
+	ruleScan := newRuleScanFunc()
+
+	for _, tc := range []struct {
+		title string
+		input string
+		want  []ybase.Token
+		err   error
+	}{
+		{
+			title: "empty",
+			input: "",
+			want:  []ybase.Token{},
+		},
+		{
+			title: "keyword priority",
+			input: "SELECT selected from x // comment\n12.5",
+			want: newTokens(
+				dfaKeyword, "SELECT",
+				dfaIdent, "selected",
+				dfaKeyword, "from",
+				dfaIdent, "x",
+				dfaNum, "12.5",
+			),
+		},
+		{
+			title: "operator priority",
+			input: "a<<=1<<2<3",
+			want: newTokens(
+				dfaIdent, "a",
+				dfaOp, "<<=",
+				dfaNum, "1",
+				dfaOp, "<<",
+				dfaNum, "2",
+				dfaOp, "<",
+				dfaNum, "3",
+			),
+		},
+		{
+			title: "unicode",
+			input: "x = 漢字 + y",
+			want: newTokens(
+				dfaIdent, "x",
+				dfaAssign, "=",
+				dfaHan, "漢字",
+				dfaOp, "+",
+				dfaIdent, "y",
+			),
+		},
+		{
+			title: "no rule matched",
+			input: "x ひらがな",
+			want: newTokens(
+				dfaIdent, "x",
+			),
+			err: ybase.ErrNoRuleMatched,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			got, err := lexAll(t, tc.input, d.ScanFunc())
+			assert.ErrorIs(t, err, tc.err)
+			assert.Equal(t, len(tc.want), len(got))
+			for i, w := range tc.want {
+				g := got[i]
+				assert.Equal(t, w.Type(), g.Type(), i)
+				assert.Equal(t, w.Value(), g.Value(), i)
+			}
+
+			ruleGot, ruleErr := lexAll(t, tc.input, ruleScan)
+			assert.ErrorIs(t, ruleErr, tc.err)
+			assert.Equal(t, len(ruleGot), len(got))
+			for i, w := range ruleGot {
+				g := got[i]
+				assert.Equal(t, w.Type(), g.Type(), i)
+				assert.Equal(t, w.Value(), g.Value(), i)
+			}
+		})
+	}
+
+	t.Run("minimize", func(t *testing.T) {
+		d, err := ybase.CompileDFA(ybase.RegexpRule{Type: 1, Pattern: `(a|b)*abb`})
+		assert.Nil(t, err)
+		assert.Equal(t, 4, len(d.States))
+	})
+
+	t.Run("match", func(t *testing.T) {
+		r := ybase.NewReader(bytes.NewBufferString("<<=x"), slog.Info)
+		index, size := d.Match(r)
+		assert.Equal(t, 5, index)
+		assert.Equal(t, 3, size)
+		assert.Equal(t, 0, r.Pos().Offset())
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		_, err := ybase.CompileDFA(ybase.RegexpRule{Type: 1, Pattern: `^a`})
+		assert.ErrorIs(t, err, ybase.ErrUnsupportedPattern)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := ybase.CompileDFA(ybase.RegexpRule{Type: 1, Pattern: `(a`})
+		assert.ErrorIs(t, err, ybase.ErrYbase)
+	})
+}
+
+var benchInput = strings.Repeat("select foo_bar from baz // comment\nx = 123.45 + (y2 - 6) * z << 2\n", 2000)
+
+func BenchmarkScan(b *testing.B) {
+	d, err := ybase.CompileDFA(dfaRules...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	handWritten := func(r ybase.Reader) int {
+		for {
+			r.DiscardWhile(unicode.IsSpace)
+			if !r.HasPrefix("//") {
+				break
+			}
+			r.DiscardWhile(func(x rune) bool { return x != '\n' && x != ybase.EOF })
+		}
+		top := r.Peek()
+		switch {
+		case top == '_' || unicode.IsLetter(top):
+			r.NextWhile(func(x rune) bool { return x == '_' || unicode.IsLetter(x) || unicode.IsDigit(x) })
+			switch strings.ToLower(r.Buffer()) {
+			case "select", "from":
+				return dfaKeyword
+			}
+			return dfaIdent
+		case unicode.IsDigit(top):
+			r.NextWhile(unicode.IsDigit)
+			_ = r.Try(func() bool {
+				if r.Next() != '.' || !unicode.IsDigit(r.Peek()) {
+					return false
+				}
+				r.NextWhile(unicode.IsDigit)
+				return true
+			})
+			return dfaNum
+		case top == '=':
+			_ = r.Next()
+			return dfaAssign
+		case top == '<':
+			_ = r.Next()
+			if r.Peek() == '<' {
+				_ = r.Next()
+				if r.Peek() == '=' {
+					_ = r.Next()
+				}
+			}
+			return dfaOp
+		case strings.ContainsRune("-+*/()", top):
+			_ = r.Next()
+			return dfaOp
+		default:
+			return ybase.EOF
+		}
+	}
+
+	for _, bc := range []struct {
+		title string
+		scan  ybase.ScanFunc
+	}{
+		{
+			title: "dfa",
+			scan:  d.ScanFunc(),
+		},
+		{
+			title: "hand-written",
+			scan:  handWritten,
+		},
+		{
+			title: "rules",
+			scan:  newRuleScanFunc(),
+		},
+	} {
+		b.Run(bc.title, func(b *testing.B) {
+			b.SetBytes(int64(len(benchInput)))
+			for b.Loop() {
+				if _, err := lexAll(b, benchInput, bc.scan); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package ybase
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Severity is the severity of Diagnostic.
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+	SeverityNote
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	case SeverityNote:
+		return "note"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+func (s Severity) color() string {
+	switch s {
+	case SeverityError:
+		return "\x1b[1;31m"
+	case SeverityWarning:
+		return "\x1b[1;33m"
+	default:
+		return "\x1b[1;36m"
+	}
+}
+
+// Diagnostic is a message about a span of the source.
+type Diagnostic struct {
+	Severity Severity
+	Message  string
+	// Start is the position of the first rune of the span.
+	Start Pos
+	// End is the position after the last rune of the span.
+	// The span is the rune at Start if End is nil or not after Start.
+	End   Pos
+	Notes []string
+}
+
+// DiagnosticRenderer renders Diagnostic like compilers:
+//
+//	error: message
+//	 --> file:6:10
+//	  |
+//	5 | spec:
+//	6 |   text1: テキスト
+//	  |          ^~~~~~~
+//	7 |   text2: text
+//	  = note: notes
+//
+// The positions are resolved by their offsets in Source.
+// Source and Filename default to the file of the positions, see WithFile.
+type DiagnosticRenderer struct {
+	// Source is the source of the positions.
+	// The source of the file of Start is used if nil.
+	Source Bytes
+	// Index is the index of Source, built on each Render if nil.
+	// Set Index to render many diagnostics of a large source.
+	Index *LineIndex
+	// Filename is prepended to the location if not empty.
+	// The name of the file of Start is used if empty.
+	Filename string
+	// Context is the number of the lines displayed before and after the span.
+	Context int
+	// Color enables ANSI colors.
+	Color bool
+}
+
+const (
+	ansiReset = "\x1b[0m"
+	ansiBold  = "\x1b[1m"
+	ansiBlue  = "\x1b[1;34m"
+)
+
+// Render renders the diagnostic.
+func (r DiagnosticRenderer) Render(d Diagnostic) string {
+	var b strings.Builder
+	paint := func(color, s string) string {
+		if !r.Color || s == "" {
+			return s
+		}
+		return color + s + ansiReset
+	}
+
+	if f := d.Start.File(); f != nil {
+		if r.Filename == "" {
+			r.Filename = f.Name()
+		}
+		if r.Source == nil {
+			r.Source = f.Source()
+			r.Index = f.Index()
+		}
+	}
+	index := r.Index
+	if index == nil {
+		index = NewLineIndex(r.Source)
+	}
+	start, end := r.span(d)
+	startLine, startCol := r.lineColumn(index, start)
+	endLine, _ := r.lineColumn(index, max(end-1, start))
+
+	firstLine := max(startLine-r.Context, 1)
+	lastLine := min(endLine+r.Context, index.LineCount())
+	width := len(fmt.Sprint(lastLine))
+	gutter := strings.Repeat(" ", width)
+
+	fmt.Fprintf(&b, "%s%s\n", paint(d.Severity.color(), d.Severity.String()), paint(ansiBold, ": "+d.Message))
+	location := fmt.Sprintf("%d:%d", startLine, startCol)
+	if r.Filename != "" {
+		location = r.Filename + ":" + location
+	}
+	fmt.Fprintf(&b, "%s%s %s\n", gutter, paint(ansiBlue, "-->"), location)
+	fmt.Fprintf(&b, "%s %s\n", gutter, paint(ansiBlue, "|"))
+
+	for linum := firstLine; linum <= lastLine; linum++ {
+		line := index.line(linum)
+		offset, _ := index.LineStart(linum)
+		fmt.Fprintf(&b, "%s %s %s\n", paint(ansiBlue, fmt.Sprintf("%*d", width, linum)), paint(ansiBlue, "|"), line)
+		if startLine <= linum && linum <= endLine {
+			from := max(start-offset, 0)
+			to := min(end-offset, len(line))
+			prefix, mark := underline(line, from, to, linum == startLine)
+			fmt.Fprintf(&b, "%s %s %s%s\n", gutter, paint(ansiBlue, "|"), prefix, paint(d.Severity.color(), mark))
+		}
+	}
+
+	for _, note := range d.Notes {
+		fmt.Fprintf(&b, "%s %s %s\n", gutter, paint(ansiBlue, "="), paint(ansiBold, "note:")+" "+note)
+	}
+	return b.String()
+}
+
+// span returns the byte offsets of the span in Source.
+func (r DiagnosticRenderer) span(d Diagnostic) (int, int) {
+	start := min(max(d.Start.Offset(), 0), len(r.Source))
+	end := start
+	if d.End != nil {
+		end = min(max(d.End.Offset(), start), len(r.Source))
+	}
+	if end == start {
+		// the rune at start
+		_, size := utf8.DecodeRune(r.Source[start:])
+		end = start + size
+	}
+	return start, end
+}
+
+// lineColumn returns the line and the rune column of the offset.
+func (DiagnosticRenderer) lineColumn(index *LineIndex, offset int) (int, int) {
+	line := sort.SearchInts(index.starts, offset+1)
+	start, _ := index.LineStart(line)
+	return line, utf8.RuneCount(index.src[start:offset]) + 1
+}
+
+// underline returns the padding before the byte range [from, to) of the line and the marks under the range.
+func underline(line []byte, from, to int, head bool) (string, string) {
+	var prefix, mark strings.Builder
+	for _, x := range string(line[:from]) {
+		if x == '\t' {
+			prefix.WriteRune('\t')
+			continue
+		}
+		prefix.WriteString(strings.Repeat(" ", runeWidth(x)))
+	}
+	for _, x := range string(line[from:to]) {
+		w := runeWidth(x)
+		if x == '\t' {
+			w = 1
+		}
+		mark.WriteString(strings.Repeat("~", w))
+	}
+	s := mark.String()
+	switch {
+	case !head:
+	case s == "":
+		// e.g. the end of the line
+		s = "^"
+	default:
+		s = "^" + s[1:]
+	}
+	return prefix.String(), s
+}
+
+// runeWidth returns the number of the cells of the rune in terminals.
+func runeWidth(r rune) int {
+	switch {
+	case r == 0, unicode.Is(unicode.Mn, r), unicode.Is(unicode.Me, r), unicode.Is(unicode.Cf, r):
+		return 0
+	case isWide(r):
+		return 2
+	default:
+		return 1
+	}
+}
+
+// wideRanges are the ranges of East Asian Wide and Fullwidth runes.
+var wideRanges = []struct{ lo, hi rune }{
+	{0x1100, 0x115F},
+	{0x231A, 0x231B},
+	{0x2E80, 0x303E},
+	{0x3041, 0x33FF},
+	{0x3400, 0x4DBF},
+	{0x4E00, 0x9FFF},
+	{0xA000, 0xA4CF},
+	{0xA960, 0xA97F},
+	{0xAC00, 0xD7A3},
+	{0xF900, 0xFAFF},
+	{0xFE10, 0xFE19},
+	{0xFE30, 0xFE6F},
+	{0xFF00, 0xFF60},
+	{0xFFE0, 0xFFE6},
+	{0x1F300, 0x1F64F},
+	{0x1F900, 0x1F9FF},
+	{0x20000, 0x2FFFD},
+	{0x30000, 0x3FFFD},
+}
+
+func isWide(r rune) bool {
+	for _, x := range wideRanges {
+		if r < x.lo {
+			return false
+		}
+		if r <= x.hi {
+			return true
+		}
+	}
+	return false
+}
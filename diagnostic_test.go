@@ -0,0 +1,161 @@
+package ybase_test
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/berquerant/ybase"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiagnosticRenderer(t *testing.T) {
+	const document = `apiVersion: v1
+kind: Text
+metadata:
+  name: sometext
+spec:
+  text1: テキスト
+	text2: text`
+	src := ybase.Bytes(document)
+
+	for _, tc := range []struct {
+		title    string
+		renderer ybase.DiagnosticRenderer
+		diag     ybase.Diagnostic
+		want     string
+	}{
+		{
+			title: "multibyte span",
+			renderer: ybase.DiagnosticRenderer{
+				Source:   src,
+				Filename: "text.yml",
+				Context:  1,
+			},
+			diag: ybase.Diagnostic{
+				Severity: ybase.SeverityError,
+				Message:  "not ascii",
+				Start:    ybase.NewPos(6, 9, 68),
+				End:      ybase.NewPos(6, 13, 80),
+				Notes:    []string{"text1 should be ascii"},
+			},
+			want: `error: not ascii
+ --> text.yml:6:10
+  |
+5 | spec:
+6 |   text1: テキスト
+  |          ^~~~~~~~
+7 | 	text2: text
+  = note: text1 should be ascii
+`,
+		},
+		{
+			title: "single rune after tab",
+			renderer: ybase.DiagnosticRenderer{
+				Source: src,
+			},
+			diag: ybase.Diagnostic{
+				Severity: ybase.SeverityWarning,
+				Message:  "tab",
+				Start:    ybase.NewPos(7, 1, 82),
+			},
+			want: `warning: tab
+ --> 7:2
+  |
+7 | 	text2: text
+  | 	^
+`,
+		},
+		{
+			title: "multiple lines",
+			renderer: ybase.DiagnosticRenderer{
+				Source:  src,
+				Context: 5,
+			},
+			diag: ybase.Diagnostic{
+				Severity: ybase.SeverityNote,
+				Message:  "kind",
+				Start:    ybase.NewPos(2, 5, 21),
+				End:      ybase.NewPos(3, 4, 30),
+			},
+			want: `note: kind
+ --> 2:7
+  |
+1 | apiVersion: v1
+2 | kind: Text
+  |       ^~~~
+3 | metadata:
+  | ~~~~
+4 |   name: sometext
+5 | spec:
+6 |   text1: テキスト
+7 | 	text2: text
+`,
+		},
+		{
+			title: "end of line",
+			renderer: ybase.DiagnosticRenderer{
+				Source: src,
+			},
+			diag: ybase.Diagnostic{
+				Severity: ybase.SeverityError,
+				Message:  "newline",
+				Start:    ybase.NewPos(1, 14, 14),
+				End:      ybase.NewPos(1, 14, 14),
+			},
+			want: `error: newline
+ --> 1:15
+  |
+1 | apiVersion: v1
+  |               ^
+`,
+		},
+		{
+			title: "color",
+			renderer: ybase.DiagnosticRenderer{
+				Source: src,
+				Color:  true,
+			},
+			diag: ybase.Diagnostic{
+				Severity: ybase.SeverityError,
+				Message:  "kind",
+				Start:    ybase.NewPos(2, 0, 15),
+				End:      ybase.NewPos(2, 4, 19),
+			},
+			want: "\x1b[1;31merror\x1b[0m\x1b[1m: kind\x1b[0m\n" +
+				" \x1b[1;34m-->\x1b[0m 2:1\n" +
+				"  \x1b[1;34m|\x1b[0m\n" +
+				"\x1b[1;34m2\x1b[0m \x1b[1;34m|\x1b[0m kind: Text\n" +
+				"  \x1b[1;34m|\x1b[0m \x1b[1;31m^~~~\x1b[0m\n",
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.renderer.Render(tc.diag))
+		})
+	}
+
+	t.Run("lex error", func(t *testing.T) {
+		input := "ab cd\nef ?"
+		s := ybase.NewLexer(ybase.NewScanner(newReader(input), ybase.NewRuleScanFunc(
+			ybase.Rule{Type: 1, Pattern: ybase.Regexp(regexp.MustCompile(`[a-z]+`))},
+			ybase.Rule{Pattern: ybase.Regexp(regexp.MustCompile(`\s+`)), Skip: true},
+		)))
+		for s.DoLex(func(ybase.Token) {}) != ybase.EOF {
+		}
+		var lexErr *ybase.LexError
+		if !assert.True(t, errors.As(s.Err(), &lexErr)) {
+			return
+		}
+		got := ybase.DiagnosticRenderer{
+			Source:  ybase.Bytes(input),
+			Context: 1,
+		}.Render(lexErr.Diagnostic())
+		assert.Equal(t, `error: unexpected '?': NoRuleMatched
+ --> 2:4
+  |
+1 | ab cd
+2 | ef ?
+  |    ^
+`, got)
+	})
+}
@@ -0,0 +1,141 @@
+package ybase
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrSyntax = errors.New("Syntax")
+
+// LexError is an error that occurred during the lexical analysis.
+//
+// errors.Is(err, ErrYbase) reports true for LexError.
+type LexError struct {
+	// Pos is the position where the error occurred.
+	Pos Pos
+	// Rune is the next rune at Pos, EOF if unknown.
+	Rune rune
+	// Buffer is the buffer of the reader when the error occurred.
+	Buffer string
+	Msg    string
+	Err    error
+}
+
+// Line returns the line of the error.
+func (e *LexError) Line() int { return e.Pos.Line() }
+
+// Column returns the column of the next rune at Pos.
+func (e *LexError) Column() int { return e.Pos.Column() + 1 }
+
+func (e *LexError) Error() string {
+	if x, ok := e.Err.(*SyntaxError); ok {
+		// the syntax error has the position and the message
+		return x.Error()
+	}
+	if e.Err == nil {
+		return fmt.Sprintf("%s: %s", FormatPos(e.Pos), e.Msg)
+	}
+	return fmt.Sprintf("%s: %s: %v", FormatPos(e.Pos), e.Msg, e.Err)
+}
+
+func (e *LexError) Unwrap() error { return e.Err }
+
+func (e *LexError) Is(target error) bool { return target == ErrYbase }
+
+// Diagnostic returns the diagnostic of the error.
+func (e *LexError) Diagnostic() Diagnostic {
+	if x, ok := e.Err.(*SyntaxError); ok {
+		return x.Diagnostic()
+	}
+	msg := e.Msg
+	if e.Err != nil {
+		msg = fmt.Sprintf("%s: %v", e.Msg, e.Err)
+	}
+	return Diagnostic{
+		Severity: SeverityError,
+		Message:  msg,
+		Start:    e.Pos,
+	}
+}
+
+// SyntaxError is a syntax error reported by goyacc.
+//
+// errors.Is(err, ErrYbase) and errors.Is(err, ErrSyntax) report true for SyntaxError.
+type SyntaxError struct {
+	// Token is the last token lexed, nil if none.
+	Token Token
+	// Start is the position of the first rune of the value of Token, End is the end of Token.
+	Start, End Pos
+	// Msg is the message from goyacc, e.g. syntax error: unexpected NUM, expecting ')' or '+'
+	Msg string
+	// Unexpected is the unexpected token name in Msg, empty if not verbose.
+	Unexpected string
+	// Expected are the expected token names in Msg.
+	Expected []string
+}
+
+// NewSyntaxError parses the message from goyacc.
+// names maps the token names of goyacc to the names to display, nil means identity.
+func NewSyntaxError(msg string, last Token, pos Pos, names func(string) string) *SyntaxError {
+	if names == nil {
+		names = func(s string) string { return s }
+	}
+	e := &SyntaxError{
+		Token: last,
+		Start: pos,
+		End:   pos,
+		Msg:   msg,
+	}
+	if last != nil {
+		e.Start = valueStart(last)
+		e.End = last.End()
+	}
+
+	const (
+		unexpectedPrefix = "syntax error: unexpected "
+		expectingSep     = ", expecting "
+	)
+	rest, ok := strings.CutPrefix(msg, unexpectedPrefix)
+	if !ok {
+		return e
+	}
+	unexpected, expecting, _ := strings.Cut(rest, expectingSep)
+	e.Unexpected = names(unexpected)
+	if expecting != "" {
+		for _, x := range strings.Split(expecting, " or ") {
+			e.Expected = append(e.Expected, names(x))
+		}
+	}
+	return e
+}
+
+// Message returns the message with the mapped names.
+func (e *SyntaxError) Message() string {
+	if e.Unexpected == "" {
+		return e.Msg
+	}
+	msg := "syntax error: unexpected " + e.Unexpected
+	if len(e.Expected) > 0 {
+		msg += ", expecting " + strings.Join(e.Expected, " or ")
+	}
+	return msg
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s: %s", FormatPos(e.Start), e.Message())
+}
+
+func (e *SyntaxError) Unwrap() error { return ErrSyntax }
+
+func (e *SyntaxError) Is(target error) bool { return target == ErrYbase }
+
+// Diagnostic returns the diagnostic of the error.
+func (e *SyntaxError) Diagnostic() Diagnostic {
+	return Diagnostic{
+		Severity: SeverityError,
+		Message:  e.Message(),
+		Start:    e.Start,
+		End:      e.End,
+	}
+}
@@ -0,0 +1,56 @@
+package ybase_test
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/berquerant/ybase"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLexError(t *testing.T) {
+	t.Run("no rule matched", func(t *testing.T) {
+		s := ybase.NewLexer(ybase.NewScanner(newReader("ab\ncd?"), ybase.NewRuleScanFunc(
+			ybase.Rule{Type: 1, Pattern: ybase.Regexp(regexp.MustCompile(`[a-z]+`))},
+			ybase.Rule{Pattern: ybase.Literal("\n"), Skip: true},
+		)))
+		for s.DoLex(func(ybase.Token) {}) != ybase.EOF {
+		}
+		err := s.Err()
+		assert.ErrorIs(t, err, ybase.ErrYbase)
+		assert.ErrorIs(t, err, ybase.ErrNoRuleMatched)
+
+		var lexErr *ybase.LexError
+		if !assert.True(t, errors.As(err, &lexErr)) {
+			return
+		}
+		assert.Equal(t, 2, lexErr.Line())
+		assert.Equal(t, 3, lexErr.Column())
+		assert.Equal(t, 5, lexErr.Pos.Offset())
+		assert.Equal(t, '?', lexErr.Rune)
+		assert.Equal(t, "", lexErr.Buffer)
+		assert.Equal(t, `input.txt:2:3: unexpected '?'`,
+			fmt.Sprintf("input.txt:%d:%d: %s", lexErr.Line(), lexErr.Column(), lexErr.Msg))
+		assert.Equal(t, `2:3: unexpected '?': NoRuleMatched`, err.Error())
+	})
+
+	t.Run("scanner error", func(t *testing.T) {
+		s := ybase.NewScanner(newReader("ab"), func(r ybase.Reader) int {
+			_ = r.Next()
+			return 1
+		})
+		_ = s.Scan()
+		s.Error("syntax error: unexpected a")
+		err := s.Err()
+		assert.ErrorIs(t, err, ybase.ErrYbase)
+		assert.ErrorIs(t, err, ybase.ErrSyntax)
+		var lexErr *ybase.LexError
+		if !assert.True(t, errors.As(err, &lexErr)) {
+			return
+		}
+		assert.Equal(t, "a", lexErr.Buffer)
+		assert.Equal(t, "1:2: syntax error: unexpected a: Syntax", err.Error())
+	})
+}
@@ -3,6 +3,7 @@ package ybase_test
 import (
 	"bytes"
 	"fmt"
+	"regexp"
 	"unicode"
 
 	"github.com/berquerant/ybase"
@@ -51,3 +52,31 @@ func ExampleLexer() {
 	// 901 56
 	// 922 )
 }
+
+func ExampleNewRuleScanFunc() {
+	input := "1 + 12 - (34-56)"
+	s := ybase.NewLexer(ybase.NewScanner(ybase.NewReader(bytes.NewBufferString(input), nil), ybase.NewRuleScanFunc(
+		ybase.Rule{Pattern: ybase.Runes(unicode.IsSpace), Skip: true},
+		ybase.Rule{Type: 901, Pattern: ybase.Regexp(regexp.MustCompile(`[0-9]+`))},
+		ybase.Rule{Type: 911, Pattern: ybase.Literal("+")},
+		ybase.Rule{Type: 912, Pattern: ybase.Literal("-")},
+		ybase.Rule{Type: 921, Pattern: ybase.Literal("(")},
+		ybase.Rule{Type: 922, Pattern: ybase.Literal(")")},
+	)))
+	for tok, err := range s.All() {
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("%d %s\n", tok.Type(), tok.Value())
+	}
+	// Output:
+	// 901 1
+	// 911 +
+	// 901 12
+	// 912 -
+	// 921 (
+	// 901 34
+	// 912 -
+	// 901 56
+	// 922 )
+}
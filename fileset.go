@@ -0,0 +1,194 @@
+package ybase
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// FilePos is a compact position in FileSet.
+// It is the base of the file plus the offset in the file.
+type FilePos int
+
+// NoFilePos is the zero FilePos, no file has it.
+const NoFilePos FilePos = 0
+
+// IsValid reports whether the position is not NoFilePos.
+func (p FilePos) IsValid() bool { return p != NoFilePos }
+
+// Position is a resolved position in a file.
+type Position struct {
+	Filename string
+	// Line is 1-based.
+	Line int
+	// Column is the 1-based byte column.
+	Column int
+	// Offset is the 0-based byte offset.
+	Offset int
+}
+
+// IsValid reports whether the position has a line.
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// String formats the position as file:line:col, omitting the absent parts.
+func (p Position) String() string {
+	s := p.Filename
+	if !p.IsValid() {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+	if s != "" {
+		s += ":"
+	}
+	return fmt.Sprintf("%s%d:%d", s, p.Line, p.Column)
+}
+
+// File is a source file in FileSet.
+type File struct {
+	name  string
+	base  int
+	src   Bytes
+	index *LineIndex
+}
+
+func (f *File) Name() string { return f.name }
+
+// Base returns the FilePos of the first byte of the file.
+func (f *File) Base() int { return f.base }
+
+// Size returns the number of the bytes of the file.
+func (f *File) Size() int { return len(f.src) }
+
+func (f *File) Source() Bytes     { return f.src }
+func (f *File) Index() *LineIndex { return f.index }
+
+// FilePos returns the FilePos of the offset.
+// The offset is clamped to the file.
+func (f *File) FilePos(offset int) FilePos {
+	return FilePos(f.base + min(max(offset, 0), len(f.src)))
+}
+
+// Offset returns the offset of the FilePos in the file.
+func (f *File) Offset(p FilePos) int {
+	return min(max(int(p)-f.base, 0), len(f.src))
+}
+
+// Position resolves the offset in the file.
+func (f *File) Position(offset int) Position {
+	offset = min(max(offset, 0), len(f.src))
+	p := Position{
+		Filename: f.name,
+		Offset:   offset,
+	}
+	p.Line = sort.SearchInts(f.index.starts, offset+1)
+	p.Column = offset - f.index.starts[p.Line-1] + 1
+	return p
+}
+
+// StartPos returns the initial Pos of the file to read.
+func (f *File) StartPos() Pos {
+	return &pos{
+		line: 1,
+		file: f,
+	}
+}
+
+// baseOf returns the base of the file, 0 if nil.
+func baseOf(f *File) int {
+	if f == nil {
+		return 0
+	}
+	return f.base
+}
+
+// FileSet is a set of source files like go/token.FileSet.
+//
+// FileSet is safe for concurrent use.
+type FileSet struct {
+	mux   sync.RWMutex
+	base  int
+	files []*File
+}
+
+func NewFileSet() *FileSet {
+	return &FileSet{
+		base: 1,
+	}
+}
+
+// AddFile registers a new file.
+func (s *FileSet) AddFile(name string, src Bytes) *File {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	f := &File{
+		name:  name,
+		base:  s.base,
+		src:   src,
+		index: NewLineIndex(src),
+	}
+	// +1 for the position at the end of the file
+	s.base += len(src) + 1
+	s.files = append(s.files, f)
+	return f
+}
+
+// File returns the file that has the position, nil if not found.
+func (s *FileSet) File(p FilePos) *File {
+	if !p.IsValid() {
+		return nil
+	}
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	i := sort.Search(len(s.files), func(i int) bool {
+		return s.files[i].base > int(p)
+	})
+	if i == 0 {
+		return nil
+	}
+	f := s.files[i-1]
+	if int(p) > f.base+len(f.src) {
+		return nil
+	}
+	return f
+}
+
+// Files returns the files in the order of registration.
+func (s *FileSet) Files() []*File {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return append([]*File(nil), s.files...)
+}
+
+// Position resolves the position.
+// Returns the zero Position if not found.
+func (s *FileSet) Position(p FilePos) Position {
+	f := s.File(p)
+	if f == nil {
+		return Position{}
+	}
+	return f.Position(f.Offset(p))
+}
+
+// FilePos returns the FilePos of the Pos, NoFilePos if the Pos has no file.
+func (s *FileSet) FilePos(p Pos) FilePos {
+	f := p.File()
+	if f == nil {
+		return NoFilePos
+	}
+	return f.FilePos(p.Offset())
+}
+
+// FormatPos formats the position as file:line:col.
+//
+// col is Column()+1, the 1-based column in the unit of PosConfig,
+// unlike Position.Column that is the byte column.
+// file is omitted if the position has no file.
+func FormatPos(p Pos) string {
+	s := fmt.Sprintf("%d:%d", p.Line(), p.Column()+1)
+	if f := p.File(); f != nil {
+		return f.Name() + ":" + s
+	}
+	return s
+}
@@ -0,0 +1,100 @@
+package ybase_test
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/berquerant/ybase"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSet(t *testing.T) {
+	fset := ybase.NewFileSet()
+	main := fset.AddFile("main.conf", ybase.Bytes("include a\nx = 1\n"))
+	sub := fset.AddFile("a.conf", ybase.Bytes("y = 2"))
+
+	t.Run("Position", func(t *testing.T) {
+		for _, tc := range []struct {
+			title string
+			pos   ybase.FilePos
+			want  string
+		}{
+			{title: "no pos", pos: ybase.NoFilePos, want: "-"},
+			{title: "main head", pos: main.FilePos(0), want: "main.conf:1:1"},
+			{title: "main second line", pos: main.FilePos(14), want: "main.conf:2:5"},
+			{title: "main eof", pos: main.FilePos(main.Size()), want: "main.conf:3:1"},
+			{title: "sub head", pos: sub.FilePos(0), want: "a.conf:1:1"},
+			{title: "sub eof", pos: sub.FilePos(sub.Size()), want: "a.conf:1:6"},
+			{title: "out of range", pos: sub.FilePos(sub.Size()) + 1, want: "-"},
+		} {
+			t.Run(tc.title, func(t *testing.T) {
+				assert.Equal(t, tc.want, fset.Position(tc.pos).String())
+			})
+		}
+	})
+
+	t.Run("File", func(t *testing.T) {
+		assert.Nil(t, fset.File(ybase.NoFilePos))
+		assert.Equal(t, main, fset.File(main.FilePos(3)))
+		assert.Equal(t, sub, fset.File(sub.FilePos(3)))
+		assert.Equal(t, []*ybase.File{main, sub}, fset.Files())
+		assert.Equal(t, 3, sub.Offset(sub.FilePos(3)))
+	})
+
+	t.Run("reader", func(t *testing.T) {
+		lex := ybase.NewLexer(ybase.NewScanner(
+			ybase.NewReader(bytes.NewReader(sub.Source()), nil, ybase.WithFile(sub)),
+			ybase.NewRuleScanFunc(
+				ybase.Rule{Type: 1, Pattern: ybase.Regexp(regexp.MustCompile(`[a-z]+`))},
+				ybase.Rule{Pattern: ybase.Literal(" "), Skip: true},
+				ybase.Rule{Type: '=', Pattern: ybase.Literal("=")},
+			),
+		))
+		tokens, err := ybase.Tokens(lex)
+		assert.ErrorIs(t, err, ybase.ErrNoRuleMatched)
+		if !assert.Len(t, tokens, 2) {
+			return
+		}
+		assert.Equal(t, sub, tokens[0].Start().File())
+		assert.Equal(t, sub.FilePos(0), fset.FilePos(tokens[0].Start()))
+		assert.Equal(t, "a.conf:1:5: unexpected '2': NoRuleMatched", err.Error())
+
+		var lexErr *ybase.LexError
+		if !assert.True(t, errors.As(err, &lexErr)) {
+			return
+		}
+		assert.Equal(t, `error: unexpected '2': NoRuleMatched
+ --> a.conf:1:5
+  |
+1 | y = 2
+  |     ^
+`, ybase.DiagnosticRenderer{}.Render(lexErr.Diagnostic()))
+	})
+
+	t.Run("compare", func(t *testing.T) {
+		a := sub.StartPos()
+		b := main.StartPos().Add('i').Add('n')
+		assert.Equal(t, 1, a.Compare(b))
+		assert.Equal(t, -1, b.Compare(a))
+		assert.Equal(t, 0, a.Compare(sub.StartPos()))
+	})
+
+	t.Run("FormatPos", func(t *testing.T) {
+		assert.Equal(t, "2:1", ybase.FormatPos(ybase.NewPos(2, 0, 4)))
+		assert.Equal(t, "main.conf:1:3", ybase.FormatPos(main.StartPos().Add('i').Add('n')))
+
+		text := fset.AddFile("text.txt", ybase.Bytes("テキスト"))
+		p := text.StartPos().Add('テ').Add('キ')
+		assert.Equal(t, "text.txt:1:3", ybase.FormatPos(p))
+		assert.Equal(t, "text.txt:1:7", text.Position(p.Offset()).String())
+
+		// the file of a decoded position has no source
+		q, err := ybase.UnmarshalPos([]byte(`{"line":3,"col":4,"offset":20,"file":"a.conf"}`))
+		if assert.Nil(t, err) {
+			assert.Equal(t, "a.conf:3:5", ybase.FormatPos(q))
+			assert.Equal(t, "a.conf:3:5: boom", (&ybase.LexError{Pos: q, Msg: "boom"}).Error())
+		}
+	})
+}
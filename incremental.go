@@ -0,0 +1,274 @@
+package ybase
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var ErrInvalidEdit = errors.New("InvalidEdit")
+
+// Edit replaces the bytes from Start to End of the source with Text.
+type Edit struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// Apply returns the edited source.
+func (e Edit) Apply(src Bytes) (Bytes, error) {
+	if e.Start < 0 || e.Start > e.End || e.End > len(src) {
+		return nil, fmt.Errorf("%w: %d-%d of %d bytes", ErrInvalidEdit, e.Start, e.End, len(src))
+	}
+	b := make(Bytes, 0, len(src)-(e.End-e.Start)+len(e.Text))
+	b = append(b, src[:e.Start]...)
+	b = append(b, e.Text...)
+	return append(b, src[e.End:]...), nil
+}
+
+// delta returns the change of the length of the source.
+func (e Edit) delta() int { return len(e.Text) - (e.End - e.Start) }
+
+// TokenStream is the tokens of a source.
+// The offsets of the tokens are the offsets in Source.
+type TokenStream struct {
+	Source    Bytes
+	Tokens    []Token
+	EOFTrivia string
+}
+
+// Change reports the re-lexed tokens:
+// the old Tokens[Start:OldEnd] are replaced by the new Tokens[Start:NewEnd],
+// the tokens before are kept and the tokens after are shifted.
+type Change struct {
+	Start  int
+	OldEnd int
+	NewEnd int
+}
+
+// IncrementalLexer re-lexes only the changed part of the source.
+type IncrementalLexer interface {
+	// Lex lexes the whole source.
+	Lex(src Bytes) (*TokenStream, error)
+	// Relex applies the edit to the old stream.
+	//
+	// Relex restarts the lexer after a token before the edit with the lexer state after the token,
+	// and stops when the new token matches an old token after the edit with the same lexer state.
+	Relex(old *TokenStream, edit Edit) (*TokenStream, Change, error)
+}
+
+type incrementalLexer struct {
+	newLexer func(Reader) Lexer
+	opts     []ReaderOption
+}
+
+// NewIncrementalLexer returns a new IncrementalLexer.
+//
+// newLexer returns a new Lexer that reads the reader, e.g.
+//
+//	func(r ybase.Reader) ybase.Lexer { return ybase.NewLexer(ybase.NewScanner(r, scan)) }
+//
+// opts configures the readers.
+//
+// The lexer state to restart the lexer is the position and the state stack of the reader, see Reader.States.
+// ScanFunc should not keep other states, e.g. the variables captured by ScanFunc,
+// and should look ahead within the next token.
+// Relex lexes the whole source if newLexer does not return the Lexer of NewLexer, e.g. NewIndentLexer.
+func NewIncrementalLexer(newLexer func(Reader) Lexer, opts ...ReaderOption) IncrementalLexer {
+	return &incrementalLexer{
+		newLexer: newLexer,
+		opts:     opts,
+	}
+}
+
+func (x *incrementalLexer) Lex(src Bytes) (*TokenStream, error) {
+	lexer := x.newLexer(NewReader(bytes.NewReader(src), nil, x.opts...))
+	tokens, err := Tokens(lexer)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenStream{
+		Source:    src,
+		Tokens:    tokens,
+		EOFTrivia: lexer.EOFTrivia(),
+	}, nil
+}
+
+func (x *incrementalLexer) Relex(old *TokenStream, edit Edit) (*TokenStream, Change, error) {
+	src, err := edit.Apply(old.Source)
+	if err != nil {
+		return nil, Change{}, err
+	}
+
+	k := restartIndex(old.Tokens, edit.Start)
+	var r Reader
+	if k < 0 {
+		r = NewReader(bytes.NewReader(src), nil, x.opts...)
+	} else {
+		prev := old.Tokens[k].(*token)
+		opts := append(slices.Clone(x.opts), WithStates(prev.states))
+		r = NewReaderWithInitPos(bytes.NewReader(src[prev.end.Offset():]), nil, prev.end, opts...)
+	}
+	l, ok := x.newLexer(r).(*lexer)
+	if !ok {
+		s, err := x.Lex(src)
+		if err != nil {
+			return nil, Change{}, err
+		}
+		return s, Change{OldEnd: len(old.Tokens), NewEnd: len(s.Tokens)}, nil
+	}
+
+	file := r.Pos().File()
+	tokens := make([]Token, 0, len(old.Tokens))
+	for _, t := range old.Tokens[:k+1] {
+		tokens = append(tokens, rebase(t, file))
+	}
+	if k >= 0 && l.lossless {
+		// recalculate the trailing trivia
+		seed := *tokens[k].(*token)
+		seed.trailing = ""
+		l.lastToken = &seed
+		tokens[k] = &seed
+	}
+
+	foreign := -1 // the old tokens before foreign cannot be shifted
+	for i, t := range old.Tokens {
+		if _, ok := t.(*token); !ok {
+			foreign = i
+		}
+	}
+
+	var (
+		delta  = edit.delta()
+		j      = k + 1
+		resync = -1
+		match  *token
+	)
+	for t, err := range l.All() {
+		if err != nil {
+			return nil, Change{}, err
+		}
+		n := t.(*token)
+		if n.states != nil && n.start.Offset() >= edit.Start+len(edit.Text) {
+			for j < len(old.Tokens) && old.Tokens[j].Start().Offset()+delta < n.start.Offset() {
+				j++
+			}
+			for i := j; i < len(old.Tokens) && old.Tokens[i].Start().Offset()+delta == n.start.Offset(); i++ {
+				if i > foreign && resyncs(old.Tokens[i].(*token), n) {
+					resync = i
+					match = n
+					break
+				}
+			}
+			if resync >= 0 {
+				break
+			}
+		}
+		tokens = append(tokens, t)
+	}
+
+	c := Change{
+		Start:  k + 1,
+		OldEnd: len(old.Tokens),
+		NewEnd: len(tokens),
+	}
+	if resync < 0 {
+		return &TokenStream{
+			Source:    src,
+			Tokens:    tokens,
+			EOFTrivia: l.EOFTrivia(),
+		}, c, nil
+	}
+
+	c.OldEnd = resync
+	o := old.Tokens[resync].(*token)
+	sh := shift{
+		line:   o.start.Line(),
+		lines:  match.start.Line() - o.start.Line(),
+		cols:   match.start.Column() - o.start.Column(),
+		offset: delta,
+		file:   file,
+	}
+	for _, t := range old.Tokens[resync:] {
+		tokens = append(tokens, sh.token(t))
+	}
+	return &TokenStream{
+		Source:    src,
+		Tokens:    tokens,
+		EOFTrivia: old.EOFTrivia,
+	}, c, nil
+}
+
+// restartIndex returns the index of the token to restart the lexer after, -1 to restart from the start.
+//
+// The lexer restarts before the token before the first token that ends at or after the edit
+// because the lexer may have looked ahead at the edited bytes while scanning the token.
+func restartIndex(tokens []Token, offset int) int {
+	first := slices.IndexFunc(tokens, func(t Token) bool { return t.End().Offset() >= offset })
+	if first < 0 {
+		first = len(tokens)
+	}
+	for k := first - 2; k >= 0; k-- {
+		if t, ok := tokens[k].(*token); ok && t.states != nil {
+			return k
+		}
+	}
+	return -1
+}
+
+// resyncs reports whether the lexer after the new token is in the same state as after the old token
+// at the same offset.
+func resyncs(o, n *token) bool {
+	if o.states == nil || o.t != n.t || o.v != n.v || o.leading != n.leading || !slices.Equal(o.states, n.states) {
+		return false
+	}
+	// the widths of the tabs depend on the columns
+	return o.start.Column() == n.start.Column() || !tabStops(n.start)
+}
+
+func tabStops(p Pos) bool {
+	x, ok := p.(*pos)
+	return ok && x.cfg != nil && x.cfg.TabWidth > 0
+}
+
+// rebase returns the token in the file.
+func rebase(t Token, file *File) Token {
+	return shift{file: file}.token(t)
+}
+
+// shift moves the positions after the resynchronization.
+type shift struct {
+	// line is the old line of the resynchronization,
+	// the columns of the positions on the line are shifted.
+	line   int
+	lines  int
+	cols   int
+	offset int
+	file   *File
+}
+
+func (s shift) token(t Token) Token {
+	x, ok := t.(*token)
+	if !ok || (s.lines == 0 && s.cols == 0 && s.offset == 0 && x.start.File() == s.file && x.end.File() == s.file) {
+		return t
+	}
+	y := *x
+	y.start = s.pos(x.start)
+	y.end = s.pos(x.end)
+	if x.vstart != nil {
+		y.vstart = s.pos(x.vstart)
+	}
+	return &y
+}
+
+func (s shift) pos(p Pos) Pos {
+	q := copyPos(p)
+	if q.line == s.line {
+		q.col += s.cols
+	}
+	q.line += s.lines
+	q.offset += s.offset
+	q.file = s.file
+	return q
+}
@@ -0,0 +1,188 @@
+package ybase_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/berquerant/ybase"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrementalLexer(t *testing.T) {
+	const (
+		IDENT = iota + 1
+		QUOTE
+		STRING
+	)
+	// "..." is a string, the lexer enters STRING at the opening quote
+	scanFuncs := map[string]ybase.ScanFunc{
+		ybase.InitialState: func(r ybase.Reader) int {
+			r.DiscardWhile(unicode.IsSpace)
+			switch x := r.Peek(); {
+			case x == ybase.EOF:
+				return ybase.EOF
+			case x == '"':
+				_ = r.Next()
+				r.PushState("STRING")
+				return QUOTE
+			case unicode.IsLetter(x) || unicode.IsDigit(x):
+				r.NextWhile(func(x rune) bool { return unicode.IsLetter(x) || unicode.IsDigit(x) })
+				return IDENT
+			}
+			_ = r.Next()
+			return int(r.Buffer()[0])
+		},
+		"STRING": func(r ybase.Reader) int {
+			switch r.Peek() {
+			case ybase.EOF:
+				return ybase.EOF
+			case '"':
+				_ = r.Next()
+				_ = r.PopState()
+				return QUOTE
+			}
+			r.NextWhile(func(x rune) bool { return x != '"' && x != ybase.EOF })
+			return STRING
+		},
+	}
+	newLexer := func(opts ...ybase.LexerOption) func(ybase.Reader) ybase.Lexer {
+		return func(r ybase.Reader) ybase.Lexer {
+			return ybase.NewLexer(ybase.NewScannerWithStates(r, scanFuncs), opts...)
+		}
+	}
+	// type value leading trailing start-end
+	dump := func(s *ybase.TokenStream) []string {
+		xs := make([]string, len(s.Tokens))
+		for i, x := range s.Tokens {
+			xs[i] = fmt.Sprintf("%d %q %q %q %s(%d)-%s(%d)",
+				x.Type(), x.Value(), x.LeadingTrivia(), x.TrailingTrivia(),
+				ybase.FormatPos(x.Start()), x.Start().Offset(), ybase.FormatPos(x.End()), x.End().Offset())
+		}
+		return append(xs, fmt.Sprintf("eof %q", s.EOFTrivia))
+	}
+
+	const src = "ab cd\n\tef \"gh ij\" kl\n\nmn + op\n"
+	edits := func() []ybase.Edit {
+		var xs []ybase.Edit
+		for i := 0; i <= len(src); i++ {
+			for _, text := range []string{"", "x", " ", "\n", "\"", "\t1 "} {
+				for _, n := range []int{0, 1, 3} {
+					if i+n <= len(src) && (text != "" || n > 0) {
+						xs = append(xs, ybase.Edit{Start: i, End: i + n, Text: text})
+					}
+				}
+			}
+		}
+		return xs
+	}()
+
+	for _, tc := range []struct {
+		title  string
+		lexer  []ybase.LexerOption
+		reader []ybase.ReaderOption
+	}{
+		{title: "default"},
+		{title: "lossless", lexer: []ybase.LexerOption{ybase.WithLossless()}},
+		{title: "tab stops", reader: []ybase.ReaderOption{ybase.WithPosConfig(ybase.PosConfig{TabWidth: 4})}},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			x := ybase.NewIncrementalLexer(newLexer(tc.lexer...), tc.reader...)
+			old, err := x.Lex(ybase.Bytes(src))
+			if !assert.Nil(t, err) {
+				return
+			}
+			for _, e := range edits {
+				got, c, err := x.Relex(old, e)
+				if !assert.Nil(t, err, "%+v", e) {
+					return
+				}
+				want, err := x.Lex(got.Source)
+				if !assert.Nil(t, err, "%+v", e) {
+					return
+				}
+				assert.Equal(t, dump(want), dump(got), "%+v %q", e, got.Source)
+				assert.LessOrEqual(t, c.Start, c.NewEnd, "%+v", e)
+				assert.LessOrEqual(t, c.Start, c.OldEnd, "%+v", e)
+				assert.Equal(t, len(old.Tokens)-c.OldEnd, len(got.Tokens)-c.NewEnd, "%+v", e)
+			}
+		})
+	}
+
+	t.Run("change", func(t *testing.T) {
+		x := ybase.NewIncrementalLexer(newLexer())
+		old, err := x.Lex(ybase.Bytes(src))
+		if !assert.Nil(t, err) {
+			return
+		}
+		// 0:ab 1:cd 2:ef 3:" 4:gh ij 5:" 6:kl 7:mn 8:+ 9:op
+		for _, tc := range []struct {
+			title string
+			edit  ybase.Edit
+			want  ybase.Change
+		}{
+			{
+				title: "replace a token",
+				edit:  ybase.Edit{Start: 27, End: 29, Text: "qrs"},
+				want:  ybase.Change{Start: 8, OldEnd: 10, NewEnd: 10},
+			},
+			{
+				title: "insert a line",
+				edit:  ybase.Edit{Start: 6, End: 6, Text: "x\n"},
+				// ef starts at the end of x because the start of a token includes the discarded runes before it
+				want: ybase.Change{Start: 1, OldEnd: 3, NewEnd: 4},
+			},
+			{
+				title: "open a string",
+				edit:  ybase.Edit{Start: 3, End: 3, Text: "\""},
+				want:  ybase.Change{Start: 0, OldEnd: 10, NewEnd: 8},
+			},
+		} {
+			t.Run(tc.title, func(t *testing.T) {
+				_, c, err := x.Relex(old, tc.edit)
+				assert.Nil(t, err)
+				assert.Equal(t, tc.want, c)
+			})
+		}
+	})
+
+	t.Run("indent lexer", func(t *testing.T) {
+		x := ybase.NewIncrementalLexer(func(r ybase.Reader) ybase.Lexer {
+			return ybase.NewIndentLexer(newLexer()(r), ybase.IndentConfig{Newline: '\n', Indent: 100, Dedent: 101})
+		})
+		old, err := x.Lex(ybase.Bytes("a b c"))
+		if !assert.Nil(t, err) {
+			return
+		}
+		got, c, err := x.Relex(old, ybase.Edit{Start: 4, End: 5, Text: "d"})
+		assert.Nil(t, err)
+		assert.Equal(t, ybase.Change{OldEnd: 4, NewEnd: 4}, c)
+		assert.Equal(t, "d", got.Tokens[2].Value())
+	})
+
+	t.Run("invalid edit", func(t *testing.T) {
+		x := ybase.NewIncrementalLexer(newLexer())
+		old, err := x.Lex(ybase.Bytes("ab"))
+		if !assert.Nil(t, err) {
+			return
+		}
+		_, _, err = x.Relex(old, ybase.Edit{Start: 1, End: 3})
+		assert.ErrorIs(t, err, ybase.ErrInvalidEdit)
+	})
+
+	t.Run("lex error", func(t *testing.T) {
+		x := ybase.NewIncrementalLexer(func(r ybase.Reader) ybase.Lexer {
+			return ybase.NewLexer(ybase.NewScanner(r, ybase.NewRuleScanFunc(
+				ybase.Rule{Pattern: ybase.Runes(unicode.IsSpace), Skip: true},
+				ybase.Rule{Type: IDENT, Pattern: ybase.Runes(unicode.IsLetter)},
+			)))
+		})
+		old, err := x.Lex(ybase.Bytes(strings.Repeat("ab ", 3)))
+		if !assert.Nil(t, err) {
+			return
+		}
+		_, _, err = x.Relex(old, ybase.Edit{Start: 3, End: 3, Text: "?"})
+		assert.ErrorIs(t, err, ybase.ErrNoRuleMatched)
+	})
+}
@@ -0,0 +1,157 @@
+package ybase
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"strings"
+)
+
+var ErrIndentation = errors.New("Indentation")
+
+// IndentConfig configures the indentation layer.
+type IndentConfig struct {
+	// Newline is the type that ScanFunc returns for a newline, e.g. Rule{Type: NEWLINE, Pattern: Literal("\n")}.
+	// The newlines of the lines that have no other tokens are dropped.
+	Newline int
+	// Indent is the type of INDENT.
+	Indent int
+	// Dedent is the type of DEDENT.
+	Dedent int
+	// Registry names INDENT, DEDENT and the NEWLINE at EOF.
+	Registry *Registry
+}
+
+type indentLexer struct {
+	Lexer
+	cfg IndentConfig
+	// stack is the indentations of the blocks, the first is the top level.
+	stack []string
+	queue []Token
+	// lineStart is true at the start of a line.
+	lineStart bool
+	// lineTokens is true if the line has tokens.
+	lineTokens bool
+	done       bool
+}
+
+// NewIndentLexer returns a new Lexer that tracks the indentations like Python.
+//
+// The lexer discards the spaces and tabs at the start of a line and emits zero-width INDENT and DEDENT
+// before the first token of a line, and NEWLINE at the end of a line that has tokens.
+// At EOF, the lexer emits NEWLINE if the last line has no newline, and DEDENT for each open block.
+//
+// The indentation must start with the indentation of the enclosing block,
+// the lexer sets ErrIndentation at the first token of the line and returns EOF
+// if the indentation mixes tabs and spaces inconsistently or does not match any outer block.
+//
+// ScanFunc should return cfg.Newline for a newline and must not skip newlines.
+func NewIndentLexer(lexer Lexer, cfg IndentConfig) Lexer {
+	return &indentLexer{
+		Lexer:     lexer,
+		cfg:       cfg,
+		stack:     []string{""},
+		lineStart: true,
+	}
+}
+
+func (l *indentLexer) All() iter.Seq2[Token, error] { return all(l.DoLex, l.Err) }
+
+func (l *indentLexer) DoLex(callback func(Token)) int {
+	for len(l.queue) == 0 {
+		if l.done {
+			return EOF
+		}
+		if !l.lex() {
+			return EOF
+		}
+	}
+	t := l.queue[0]
+	l.queue = l.queue[1:]
+	callback(t)
+	return t.Type()
+}
+
+// lex reads a token and queues the tokens to emit.
+// Returns false on an error.
+func (l *indentLexer) lex() bool {
+	var indent string
+	if l.lineStart {
+		var b strings.Builder
+		for x := l.Peek(); x == ' ' || x == '\t'; x = l.Peek() {
+			b.WriteRune(l.Discard())
+		}
+		indent = b.String()
+	}
+	pos := l.Pos()
+
+	var tok Token
+	if l.Lexer.DoLex(func(t Token) { tok = t }) == EOF {
+		if l.Err() != nil {
+			return false
+		}
+		l.done = true
+		if l.lineTokens {
+			l.queue = append(l.queue, l.synthetic(l.cfg.Newline, pos))
+		}
+		for range l.stack[1:] {
+			l.queue = append(l.queue, l.synthetic(l.cfg.Dedent, pos))
+		}
+		l.stack = l.stack[:1]
+		return true
+	}
+
+	if tok.Type() == l.cfg.Newline {
+		if l.lineTokens {
+			l.queue = append(l.queue, tok)
+		}
+		l.lineStart = true
+		l.lineTokens = false
+		return true
+	}
+
+	if l.lineStart {
+		if !l.indent(indent, pos) {
+			return false
+		}
+		l.lineStart = false
+	}
+	l.lineTokens = true
+	l.queue = append(l.queue, tok)
+	return true
+}
+
+// indent queues INDENT or DEDENT by the indentation of the line at pos.
+func (l *indentLexer) indent(indent string, pos Pos) bool {
+	top := l.stack[len(l.stack)-1]
+	switch {
+	case indent == top:
+		return true
+	case strings.HasPrefix(indent, top):
+		l.stack = append(l.stack, indent)
+		l.queue = append(l.queue, l.synthetic(l.cfg.Indent, pos))
+		return true
+	case !strings.HasPrefix(top, indent):
+		l.errorf(pos, "inconsistent use of tabs and spaces: %q after %q", indent, top)
+		return false
+	}
+	for len(l.stack) > 1 && len(l.stack[len(l.stack)-1]) > len(indent) {
+		l.stack = l.stack[:len(l.stack)-1]
+		l.queue = append(l.queue, l.synthetic(l.cfg.Dedent, pos))
+	}
+	if l.stack[len(l.stack)-1] != indent {
+		l.queue = nil
+		l.errorf(pos, "unindent does not match any outer indentation level: %q", indent)
+		return false
+	}
+	return true
+}
+
+func (l *indentLexer) synthetic(t int, pos Pos) Token {
+	return NewTokenWithRegistry(t, "", pos, pos, l.cfg.Registry)
+}
+
+// errorf sets the error at pos, not at the current position after the token.
+func (l *indentLexer) errorf(pos Pos, format string, v ...any) {
+	l.ErrorfAt(pos, ErrIndentation, fmt.Sprintf(format, v...))
+}
@@ -0,0 +1,164 @@
+package ybase_test
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/berquerant/ybase"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndentLexer(t *testing.T) {
+	const (
+		NAME = iota + 1
+		NEWLINE
+		INDENT
+		DEDENT
+	)
+	reg := ybase.NewRegistry().
+		Register(NAME, "NAME", ybase.CategoryIdentifier).
+		Register(NEWLINE, "NEWLINE", ybase.CategoryPunctuation).
+		Register(INDENT, "INDENT", ybase.CategoryPunctuation).
+		Register(DEDENT, "DEDENT", ybase.CategoryPunctuation).
+		Register(':', "':'", ybase.CategoryPunctuation)
+	newLexer := func(input string) ybase.Lexer {
+		return ybase.NewIndentLexer(ybase.NewLexer(ybase.NewScanner(newReader(input), ybase.NewRuleScanFunc(
+			ybase.Rule{Pattern: ybase.Regexp(regexp.MustCompile(`[ \t]+|#[^\n]*`)), Skip: true},
+			ybase.Rule{Type: NEWLINE, Pattern: ybase.Literal("\n")},
+			ybase.Rule{Type: NAME, Pattern: ybase.Regexp(regexp.MustCompile(`[a-z0-9]+`))},
+			ybase.Rule{Type: ':', Pattern: ybase.Literal(":")},
+		)), ybase.WithRegistry(reg)), ybase.IndentConfig{
+			Newline:  NEWLINE,
+			Indent:   INDENT,
+			Dedent:   DEDENT,
+			Registry: reg,
+		})
+	}
+	// name value line:col-line:col
+	dump := func(tokens []ybase.Token) []string {
+		xs := make([]string, len(tokens))
+		for i, x := range tokens {
+			xs[i] = fmt.Sprintf("%s %q %s-%s", x.Kind().Name, x.Value(), ybase.FormatPos(x.Start()), ybase.FormatPos(x.End()))
+		}
+		return xs
+	}
+
+	for _, tc := range []struct {
+		title string
+		input string
+		want  []string
+		err   string
+	}{
+		{
+			title: "empty",
+			input: "",
+			want:  []string{},
+		},
+		{
+			title: "blocks",
+			input: `spec:
+  text1: a
+  nested:
+    x: 1
+
+  # comment
+  y: 2
+z: 3
+`,
+			want: []string{
+				`NAME "spec" 1:1-1:5`,
+				`':' ":" 1:5-1:6`,
+				`NEWLINE "\n" 1:6-2:1`,
+				`INDENT "" 2:3-2:3`,
+				`NAME "text1" 2:1-2:8`,
+				`':' ":" 2:8-2:9`,
+				`NAME "a" 2:9-2:11`,
+				`NEWLINE "\n" 2:11-3:1`,
+				`NAME "nested" 3:1-3:9`,
+				`':' ":" 3:9-3:10`,
+				`NEWLINE "\n" 3:10-4:1`,
+				`INDENT "" 4:5-4:5`,
+				`NAME "x" 4:1-4:6`,
+				`':' ":" 4:6-4:7`,
+				`NAME "1" 4:7-4:9`,
+				`NEWLINE "\n" 4:9-5:1`,
+				`DEDENT "" 7:3-7:3`,
+				`NAME "y" 7:1-7:4`,
+				`':' ":" 7:4-7:5`,
+				`NAME "2" 7:5-7:7`,
+				`NEWLINE "\n" 7:7-8:1`,
+				`DEDENT "" 8:1-8:1`,
+				`NAME "z" 8:1-8:2`,
+				`':' ":" 8:2-8:3`,
+				`NAME "3" 8:3-8:5`,
+				`NEWLINE "\n" 8:5-9:1`,
+			},
+		},
+		{
+			title: "no newline at eof",
+			input: "a\n\tb",
+			want: []string{
+				`NAME "a" 1:1-1:2`,
+				`NEWLINE "\n" 1:2-2:1`,
+				`INDENT "" 2:2-2:2`,
+				`NAME "b" 2:1-2:3`,
+				`NEWLINE "" 2:3-2:3`,
+				`DEDENT "" 2:3-2:3`,
+			},
+		},
+		{
+			title: "dedent multiple levels",
+			input: "a\n b\n  c\nd\n",
+			want: []string{
+				`NAME "a" 1:1-1:2`,
+				`NEWLINE "\n" 1:2-2:1`,
+				`INDENT "" 2:2-2:2`,
+				`NAME "b" 2:1-2:3`,
+				`NEWLINE "\n" 2:3-3:1`,
+				`INDENT "" 3:3-3:3`,
+				`NAME "c" 3:1-3:4`,
+				`NEWLINE "\n" 3:4-4:1`,
+				`DEDENT "" 4:1-4:1`,
+				`DEDENT "" 4:1-4:1`,
+				`NAME "d" 4:1-4:2`,
+				`NEWLINE "\n" 4:2-5:1`,
+			},
+		},
+		{
+			title: "tabs after spaces",
+			input: "a\n  b\n\tc\n",
+			want: []string{
+				`NAME "a" 1:1-1:2`,
+				`NEWLINE "\n" 1:2-2:1`,
+				`INDENT "" 2:3-2:3`,
+				`NAME "b" 2:1-2:4`,
+				`NEWLINE "\n" 2:4-3:1`,
+			},
+			err: `3:2: inconsistent use of tabs and spaces: "\t" after "  ": Indentation`,
+		},
+		{
+			title: "unmatched dedent",
+			input: "a\n    b\n  c\n",
+			want: []string{
+				`NAME "a" 1:1-1:2`,
+				`NEWLINE "\n" 1:2-2:1`,
+				`INDENT "" 2:5-2:5`,
+				`NAME "b" 2:1-2:6`,
+				`NEWLINE "\n" 2:6-3:1`,
+			},
+			err: `3:3: unindent does not match any outer indentation level: "  ": Indentation`,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			tokens, err := ybase.Tokens(newLexer(tc.input))
+			assert.Equal(t, tc.want, dump(tokens))
+			if tc.err == "" {
+				assert.Nil(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ybase.ErrIndentation)
+			assert.Equal(t, tc.err, fmt.Sprint(err))
+		})
+	}
+}
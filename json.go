@@ -0,0 +1,220 @@
+package ybase
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strconv"
+)
+
+var ErrDecode = errors.New("Decode")
+
+type (
+	posJSON struct {
+		Line   int    `json:"line"`
+		Col    int    `json:"col"`
+		Offset int    `json:"offset"`
+		File   string `json:"file"`
+	}
+
+	tokenJSON struct {
+		Type     int      `json:"type"`
+		Name     string   `json:"name"`
+		Category string   `json:"category"`
+		Value    string   `json:"value"`
+		Start    *posJSON `json:"start"`
+		End      *posJSON `json:"end"`
+		Leading  string   `json:"leading"`
+		Trailing string   `json:"trailing"`
+	}
+)
+
+// UnmarshalPos decodes the JSON made by Pos.MarshalJSON.
+//
+// The file of the position is a new File with the name and no source.
+func UnmarshalPos(data []byte) (Pos, error) {
+	var v posJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("%w: pos: %w", ErrDecode, err)
+	}
+	return newFileResolver(nil).pos(&v), nil
+}
+
+// UnmarshalToken decodes the JSON made by Token.MarshalJSON.
+//
+// The kind of the token is from the JSON.
+func UnmarshalToken(data []byte) (Token, error) {
+	d := &TokenDecoder{
+		files: newFileResolver(nil),
+	}
+	t, err := d.unmarshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: token: %w", ErrDecode, err)
+	}
+	return t, nil
+}
+
+// TokenEncoder writes tokens as NDJSON, a JSON made by Token.MarshalJSON per line.
+type TokenEncoder struct {
+	w io.Writer
+}
+
+func NewTokenEncoder(w io.Writer) *TokenEncoder {
+	return &TokenEncoder{
+		w: w,
+	}
+}
+
+// Encode writes the token.
+func (e *TokenEncoder) Encode(t Token) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(append(b, '\n'))
+	return err
+}
+
+// EncodeAll writes the tokens until seq ends or yields an error, e.g. Lexer.All.
+func (e *TokenEncoder) EncodeAll(seq iter.Seq2[Token, error]) error {
+	for t, err := range seq {
+		if err != nil {
+			return err
+		}
+		if err := e.Encode(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TokenDecoder reads tokens written by TokenEncoder.
+type TokenDecoder struct {
+	dec   *json.Decoder
+	reg   *Registry
+	own   bool // reg is made by the decoder
+	files *fileResolver
+	line  int
+}
+
+// NewTokenDecoder returns a new TokenDecoder.
+//
+// reg gives the kinds of the tokens, the kinds in the stream are registered to a new Registry if nil.
+// fset resolves the file names, the files not in fset are new Files with the names and no sources.
+func NewTokenDecoder(r io.Reader, reg *Registry, fset *FileSet) *TokenDecoder {
+	return &TokenDecoder{
+		dec:   json.NewDecoder(r),
+		reg:   reg,
+		files: newFileResolver(fset),
+	}
+}
+
+// Decode reads the next token.
+// Returns io.EOF at the end of the stream.
+func (d *TokenDecoder) Decode() (Token, error) {
+	var raw json.RawMessage
+	if err := d.dec.Decode(&raw); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("%w: token %d: %w", ErrDecode, d.line+1, err)
+	}
+	d.line++
+	t, err := d.unmarshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: token %d: %w", ErrDecode, d.line, err)
+	}
+	return t, nil
+}
+
+// All returns an iterator over the tokens.
+// The iteration stops after yielding an error.
+func (d *TokenDecoder) All() iter.Seq2[Token, error] {
+	return func(yield func(Token, error) bool) {
+		for {
+			t, err := d.Decode()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if !yield(t, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (d *TokenDecoder) unmarshal(data []byte) (Token, error) {
+	var v tokenJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	if v.Start == nil || v.End == nil {
+		return nil, errors.New("missing start or end")
+	}
+	if d.reg == nil {
+		d.reg = NewRegistry()
+		d.own = true
+	}
+	if d.own && !d.reg.Contains(v.Type) && v.Name != "" && v.Name != strconv.Itoa(v.Type) {
+		d.reg.Register(v.Type, v.Name, parseCategory(v.Category))
+	}
+	return &token{
+		t:        v.Type,
+		v:        v.Value,
+		start:    d.files.pos(v.Start),
+		end:      d.files.pos(v.End),
+		reg:      d.reg,
+		leading:  v.Leading,
+		trailing: v.Trailing,
+	}, nil
+}
+
+// fileResolver finds the files by the names.
+type fileResolver struct {
+	fset  *FileSet
+	files map[string]*File
+}
+
+func newFileResolver(fset *FileSet) *fileResolver {
+	return &fileResolver{
+		fset:  fset,
+		files: map[string]*File{},
+	}
+}
+
+func (r *fileResolver) file(name string) *File {
+	if name == "" {
+		return nil
+	}
+	if f, ok := r.files[name]; ok {
+		return f
+	}
+	var f *File
+	if r.fset != nil {
+		for _, x := range r.fset.Files() {
+			if x.Name() == name {
+				f = x
+				break
+			}
+		}
+	}
+	if f == nil {
+		f = &File{
+			name:  name,
+			index: NewLineIndex(nil),
+		}
+	}
+	r.files[name] = f
+	return f
+}
+
+func (r *fileResolver) pos(v *posJSON) Pos {
+	return &pos{
+		line:   v.Line,
+		col:    v.Col,
+		offset: v.Offset,
+		file:   r.file(v.File),
+	}
+}
@@ -0,0 +1,156 @@
+package ybase_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/berquerant/ybase"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenJSON(t *testing.T) {
+	fset := ybase.NewFileSet()
+	file := fset.AddFile("input.txt", ybase.Bytes("ab 12\ncd"))
+	reg := ybase.NewRegistry().
+		Register(1, "IDENT", ybase.CategoryIdentifier)
+	newLexer := func() ybase.Lexer {
+		return ybase.NewLexer(ybase.NewScanner(
+			ybase.NewReader(bytes.NewReader(file.Source()), nil, ybase.WithFile(file)),
+			ybase.NewRuleScanFunc(
+				ybase.Rule{Pattern: ybase.Runes(unicode.IsSpace), Skip: true},
+				ybase.Rule{Type: 1, Pattern: ybase.Runes(unicode.IsLetter)},
+				ybase.Rule{Type: 10, Pattern: ybase.Runes(unicode.IsDigit)},
+			),
+		), ybase.WithRegistry(reg))
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(t, ybase.NewTokenEncoder(&buf).EncodeAll(newLexer().All()))
+	assert.Equal(t, `{"category":"identifier","end":{"col":2,"file":"input.txt","line":1,"offset":2},"name":"IDENT","start":{"col":0,"file":"input.txt","line":1,"offset":0},"type":1,"value":"ab"}
+{"category":"unknown","end":{"col":5,"file":"input.txt","line":1,"offset":5},"name":"10","start":{"col":2,"file":"input.txt","line":1,"offset":2},"type":10,"value":"12"}
+{"category":"identifier","end":{"col":2,"file":"input.txt","line":2,"offset":8},"name":"IDENT","start":{"col":5,"file":"input.txt","line":1,"offset":5},"type":1,"value":"cd"}
+`, buf.String())
+
+	want, err := ybase.Tokens(newLexer())
+	assert.Nil(t, err)
+
+	type flat struct {
+		Type       int
+		Kind       ybase.TokenKind
+		Value      string
+		Start, End string
+		File       *ybase.File
+	}
+	flatten := func(tokens []ybase.Token) []flat {
+		xs := make([]flat, len(tokens))
+		for i, x := range tokens {
+			xs[i] = flat{
+				Type:  x.Type(),
+				Kind:  x.Kind(),
+				Value: x.Value(),
+				Start: fmt.Sprint(x.Start()),
+				End:   fmt.Sprint(x.End()),
+				File:  x.Start().File(),
+			}
+		}
+		return xs
+	}
+
+	t.Run("decode with fileset", func(t *testing.T) {
+		var got []ybase.Token
+		for x, err := range ybase.NewTokenDecoder(strings.NewReader(buf.String()), nil, fset).All() {
+			assert.Nil(t, err)
+			got = append(got, x)
+		}
+		assert.Equal(t, flatten(want), flatten(got))
+	})
+
+	t.Run("decode with registry", func(t *testing.T) {
+		reg := ybase.NewRegistry().Register(10, "NUM", ybase.CategoryLiteral)
+		d := ybase.NewTokenDecoder(strings.NewReader(buf.String()), reg, nil)
+		var got []ybase.Token
+		for {
+			x, err := d.Decode()
+			if err == io.EOF {
+				break
+			}
+			assert.Nil(t, err)
+			got = append(got, x)
+		}
+		if !assert.Len(t, got, 3) {
+			return
+		}
+		assert.Equal(t, "1,ab", fmt.Sprint(got[0]))
+		assert.Equal(t, "NUM,12", fmt.Sprint(got[1]))
+		assert.False(t, reg.Contains(1))
+		assert.Equal(t, "input.txt", got[0].Start().File().Name())
+		assert.Same(t, got[0].Start().File(), got[2].End().File())
+	})
+
+	t.Run("decode error", func(t *testing.T) {
+		d := ybase.NewTokenDecoder(strings.NewReader(`{"type":1,"start":{},"end":{}}
+{"type":1}
+`), nil, nil)
+		_, err := d.Decode()
+		assert.Nil(t, err)
+		_, err = d.Decode()
+		assert.ErrorIs(t, err, ybase.ErrDecode)
+		assert.ErrorContains(t, err, "token 2")
+	})
+
+	t.Run("UnmarshalToken", func(t *testing.T) {
+		b, err := json.Marshal(want[0])
+		assert.Nil(t, err)
+		got, err := ybase.UnmarshalToken(b)
+		assert.Nil(t, err)
+		assert.Equal(t, ybase.TokenKind{Type: 1, Name: "IDENT", Category: ybase.CategoryIdentifier}, got.Kind())
+		assert.Equal(t, "ab", got.Value())
+		assert.Equal(t, "1,2,2", fmt.Sprint(got.End()))
+		assert.Equal(t, "input.txt", got.End().File().Name())
+
+		_, err = ybase.UnmarshalToken([]byte(`{"type":1}`))
+		assert.ErrorIs(t, err, ybase.ErrDecode)
+	})
+
+	t.Run("trivia", func(t *testing.T) {
+		lexer := ybase.NewLexer(ybase.NewScanner(
+			ybase.NewReader(bytes.NewReader(file.Source()), nil),
+			ybase.NewRuleScanFunc(
+				ybase.Rule{Pattern: ybase.Runes(unicode.IsSpace), Skip: true},
+				ybase.Rule{Type: 1, Pattern: ybase.Runes(unicode.IsLetter)},
+				ybase.Rule{Type: 10, Pattern: ybase.Runes(unicode.IsDigit)},
+			),
+		), ybase.WithLossless())
+		var buf bytes.Buffer
+		assert.Nil(t, ybase.NewTokenEncoder(&buf).EncodeAll(lexer.All()))
+		assert.Contains(t, buf.String(), `"trailing":"\n"`)
+
+		var got []string
+		for x, err := range ybase.NewTokenDecoder(&buf, nil, nil).All() {
+			assert.Nil(t, err)
+			got = append(got, fmt.Sprintf("%q %q %q", x.LeadingTrivia(), x.Value(), x.TrailingTrivia()))
+		}
+		assert.Equal(t, []string{
+			`"" "ab" " "`,
+			`"" "12" "\n"`,
+			`"" "cd" ""`,
+		}, got)
+	})
+
+	t.Run("UnmarshalPos", func(t *testing.T) {
+		p := ybase.NewPos(2, 3, 10)
+		b, err := json.Marshal(p)
+		assert.Nil(t, err)
+		got, err := ybase.UnmarshalPos(b)
+		assert.Nil(t, err)
+		assert.Equal(t, p, got)
+
+		_, err = ybase.UnmarshalPos([]byte(`[]`))
+		assert.ErrorIs(t, err, ybase.ErrDecode)
+	})
+}
@@ -6,12 +6,27 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"log/slog"
+	"slices"
+	"strings"
 )
 
 const EOF = -1
 
-var ErrYbase = errors.New("Ybase")
+// ErrorToken is the value of the error token of goyacc.
+// Lexer returns it on an error if the recovery is enabled.
+const ErrorToken = 57344
+
+// InitialState is the bottom of the lexer state stack.
+const InitialState = "INITIAL"
+
+var (
+	ErrYbase           = errors.New("Ybase")
+	ErrInvalidMark     = errors.New("InvalidMark")
+	ErrUnbalancedState = errors.New("UnbalancedState")
+	ErrUnknownState    = errors.New("UnknownState")
+)
 
 // DebugFunc outputs debug logs.
 // Assuming a function like slog.Debug.
@@ -25,71 +40,247 @@ type Reader interface {
 	ResetBuffer()
 	// Buffer returns the read runes.
 	Buffer() string
+	// BufferStart returns the position of the first rune in the buffer,
+	// the current position if the buffer is empty.
+	BufferStart() Pos
+	// TakeConsumed returns the runes consumed by Next and Discard since the last call and forgets them.
+	// The reader records the consumed runes after the first call.
+	TakeConsumed() string
 	// Next gets the next rune and advances the pos.
 	Next() rune
 	// Peek gets the next rune but keeps the pos.
 	Peek() rune
+	// PeekN gets the next k runes but keeps the pos.
+	// Returns fewer runes if the input ends before.
+	PeekN(k int) []rune
+	// PeekString gets the next n runes as a string but keeps the pos.
+	PeekString(n int) string
+	// HasPrefix reports whether the next runes are s.
+	HasPrefix(s string) bool
 	// Discard ignores the next rune.
 	Discard() rune
 	// Err returns an error during the reading.
+	// The error is a *LexError.
 	Err() error
+	// ResetErr clears the error.
+	ResetErr()
 	// Debugf outputs debug logs.
 	Debugf(msg string, v ...any)
-	// Errorf outputs logs and set an error.
+	// DebugEnabled reports whether Debugf outputs logs.
+	// Check it before building the arguments of Debugf on hot paths.
+	DebugEnabled() bool
+	// Errorf outputs logs and set a *LexError at the current position.
+	// The first error is kept until ResetErr.
 	Errorf(err error, msg string, v ...any)
+	// ErrorfAt is Errorf at the position, e.g. the start of a token.
+	ErrorfAt(p Pos, err error, msg string, v ...any)
 	// DiscardWhile calls Discard() while pred(Peek()).
 	DiscardWhile(pred func(rune) bool)
 	// NextWhile calls Next() while pred(Peek()).
 	NextWhile(pred func(rune) bool)
 	// Pos returns the current position.
 	Pos() Pos
+	// Mark creates a checkpoint of the pos, the buffer and the input.
+	// The checkpoint should be released by Release when no longer needed.
+	Mark() Mark
+	// Reset rewinds the reader to the checkpoint.
+	// The checkpoint remains valid until released.
+	Reset(m Mark)
+	// Release discards the checkpoint.
+	Release(m Mark)
+	// Try calls f and rewinds the reader if f returns false.
+	Try(f func() bool) bool
+	// PushState enters the lexer state.
+	PushState(state string)
+	// PopState leaves the current lexer state and returns it.
+	// Sets ErrUnbalancedState if the current state is InitialState.
+	PopState() string
+	// CurrentState returns the current lexer state.
+	CurrentState() string
+	// States returns a copy of the lexer state stack except InitialState, the bottom first.
+	States() []string
+	// Emit queues a token of the span, the current position if the span is zero.
+	// Lexer returns the queued tokens before the token returned by ScanFunc.
+	// To replace the token returned by ScanFunc, e.g. split >> into > and >,
+	// emit the tokens and return EOF, then Lexer resets the buffer and scans again after the queued tokens.
+	Emit(t int, value string, span Span)
+	// TakeEmitted removes the queued tokens and returns them.
+	TakeEmitted() []Emission
 }
 
+// Emission is a token queued by Reader.Emit.
+type Emission struct {
+	Type  int
+	Value string
+	Span  Span
+}
+
+// Mark is a checkpoint of Reader.
+type Mark struct {
+	id       int
+	nread    int
+	pos      Pos
+	buf      string
+	bufStart Pos
+	err      error
+	states   []string
+	emitted  int
+}
+
+// ID returns the identifier of the mark, unique in the reader.
+func (m Mark) ID() int { return m.id }
+
 type reader struct {
 	pos       Pos
 	rdr       *bufio.Reader
+	ahead     []rune // runes read from rdr but not consumed yet
 	buf       bytes.Buffer
 	err       error
 	debugFunc DebugFunc
+	debug     bool     // false if no debugFunc given
+	states    []string // lexer state stack except InitialState
+	emitted   []Emission
+	bufStart  Pos // position of the first rune in buf
+
+	recording    bool   // record the consumed runes
+	consumed     []rune // consumed runes since consumedBase
+	consumedBase int    // nread at consumed[0]
+
+	nread    int         // number of consumed runes
+	history  []rune      // consumed runes since histBase, kept while marks are alive
+	histBase int         // nread at history[0]
+	marks    map[int]int // mark id to nread
+	markID   int
+}
+
+// ReaderOption configures Reader.
+type ReaderOption func(*reader)
+
+// WithPosConfig configures the position tracking.
+func WithPosConfig(cfg PosConfig) ReaderOption {
+	return func(r *reader) {
+		p := copyPos(r.pos)
+		p.cfg = &cfg
+		r.pos = p
+	}
+}
+
+// WithFile sets the file of the positions.
+func WithFile(f *File) ReaderOption {
+	return func(r *reader) {
+		p := copyPos(r.pos)
+		p.file = f
+		r.pos = p
+	}
 }
 
-func NewReaderWithInitPos(rdr io.Reader, debugFunc DebugFunc, initPos Pos) Reader {
-	if debugFunc == nil {
+// WithStates sets the initial lexer state stack except InitialState, the bottom first.
+func WithStates(states []string) ReaderOption {
+	return func(r *reader) {
+		r.states = slices.Clone(states)
+	}
+}
+
+func NewReaderWithInitPos(rdr io.Reader, debugFunc DebugFunc, initPos Pos, opts ...ReaderOption) Reader {
+	debug := debugFunc != nil
+	if !debug {
 		debugFunc = NilDebugFunc
 	}
-	return &reader{
+	r := &reader{
 		pos:       initPos,
 		rdr:       bufio.NewReader(rdr),
 		debugFunc: debugFunc,
+		debug:     debug,
+		marks:     map[int]int{},
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
-func NewReader(rdr io.Reader, debugFunc DebugFunc) Reader {
-	return NewReaderWithInitPos(rdr, debugFunc, NewPos(1, 0, 0))
+func NewReader(rdr io.Reader, debugFunc DebugFunc, opts ...ReaderOption) Reader {
+	return NewReaderWithInitPos(rdr, debugFunc, NewPos(1, 0, 0), opts...)
 }
 
-func (r reader) Pos() Pos       { return r.pos }
+func (r reader) Pos() Pos { return r.pos }
+func (r *reader) Emit(t int, value string, span Span) {
+	if span.IsZero() {
+		span = NewSpan(r.pos, r.pos)
+	}
+	if r.debug {
+		r.Debugf("Emit", slog.Int("type", t), slog.String("value", value))
+	}
+	r.emitted = append(r.emitted, Emission{
+		Type:  t,
+		Value: value,
+		Span:  span,
+	})
+}
+func (r *reader) TakeEmitted() []Emission {
+	xs := r.emitted
+	r.emitted = nil
+	return xs
+}
 func (r *reader) ResetBuffer()  { r.buf.Reset() }
 func (r reader) Buffer() string { return r.buf.String() }
-func (r reader) Err() error     { return r.err }
+func (r reader) BufferStart() Pos {
+	if r.buf.Len() == 0 {
+		return r.pos
+	}
+	return r.bufStart
+}
+func (r *reader) TakeConsumed() string {
+	s := string(r.consumed)
+	r.recording = true
+	r.consumed = r.consumed[:0]
+	r.consumedBase = r.nread
+	return s
+}
+func (r reader) Err() error { return r.err }
+func (r *reader) ResetErr() { r.err = nil }
 func (r reader) logAttrs() []any {
 	return []any{
 		slog.Int("line", r.pos.Line()),
 		slog.Int("column", r.pos.Column()),
 		slog.Int("offset", r.pos.Offset()),
 		slog.String("buf", r.buf.String()),
+		slog.String("state", r.CurrentState()),
 	}
 }
+func (r reader) DebugEnabled() bool { return r.debug }
 func (r reader) Debugf(msg string, v ...any) {
+	if !r.debug {
+		return
+	}
 	attrs := r.logAttrs()
 	attrs = append(attrs, v...)
 	r.debugFunc("ybase: "+msg, attrs...)
 }
 func (r *reader) Errorf(err error, msg string, v ...any) {
-	r.err = errors.Join(ErrYbase, fmt.Errorf("%w: %s", err, msg))
+	r.ErrorfAt(r.pos, err, msg, v...)
+}
+func (r *reader) ErrorfAt(p Pos, err error, msg string, v ...any) {
+	x := rune(EOF)
+	if len(r.ahead) > 0 && p.Compare(r.pos) == 0 {
+		x = r.ahead[0]
+	}
+	lexErr := &LexError{
+		Pos:    p,
+		Rune:   x,
+		Buffer: r.buf.String(),
+		Msg:    msg,
+		Err:    err,
+	}
+	if r.err == nil {
+		r.err = lexErr
+	}
+	if !r.debug {
+		return
+	}
 	attrs := r.logAttrs()
 	attrs = append(attrs, v...)
-	attrs = append(attrs, slog.Any("err", r.err))
+	attrs = append(attrs, slog.Any("err", lexErr))
 	r.debugFunc("ybase: "+msg, attrs...)
 }
 
@@ -105,9 +296,139 @@ func (r *reader) NextWhile(pred func(rune) bool) {
 	}
 }
 
+// fill reads runes from rdr until the lookahead holds n runes.
+func (r *reader) fill(n int) error {
+	for len(r.ahead) < n {
+		g, _, err := r.rdr.ReadRune()
+		if err != nil {
+			return err
+		}
+		r.ahead = append(r.ahead, g)
+	}
+	return nil
+}
+
+// readRune gets the next rune from the lookahead and consumes it.
+func (r *reader) readRune() (rune, error) {
+	if err := r.fill(1); err != nil {
+		return 0, err
+	}
+	g := r.ahead[0]
+	r.ahead = r.ahead[1:]
+	r.nread++
+	if len(r.marks) > 0 {
+		r.history = append(r.history, g)
+	}
+	if r.recording {
+		r.consumed = append(r.consumed, g)
+	}
+	return g, nil
+}
+
+func (r *reader) Mark() Mark {
+	if len(r.marks) == 0 {
+		r.history = r.history[:0]
+		r.histBase = r.nread
+	}
+	r.markID++
+	m := Mark{
+		id:       r.markID,
+		nread:    r.nread,
+		pos:      r.pos,
+		buf:      r.buf.String(),
+		bufStart: r.bufStart,
+		err:      r.err,
+		states:   slices.Clone(r.states),
+		emitted:  len(r.emitted),
+	}
+	r.marks[m.id] = m.nread
+	if r.debug {
+		r.Debugf("Mark", slog.Int("mark", m.id))
+	}
+	return m
+}
+
+func (r *reader) Reset(m Mark) {
+	if r.debug {
+		r.Debugf("Reset", slog.Int("mark", m.id))
+	}
+	if _, ok := r.marks[m.id]; !ok {
+		r.Errorf(ErrInvalidMark, "Reset")
+		return
+	}
+	if n := m.nread - r.nread; n > 0 {
+		// the mark is after the current position, e.g. reset to an inner mark after reset to an outer mark,
+		// the runes are in ahead since the previous reset
+		_ = r.fill(n)
+		r.history = append(r.history, r.ahead[:n]...)
+		if r.recording {
+			r.consumed = append(r.consumed, r.ahead[:n]...)
+		}
+		r.ahead = r.ahead[n:]
+		r.nread = m.nread
+	}
+	i := m.nread - r.histBase
+	r.ahead = append(slices.Clone(r.history[i:]), r.ahead...)
+	r.history = r.history[:i]
+	r.nread = m.nread
+	r.pos = m.pos
+	r.buf.Reset()
+	_, _ = r.buf.WriteString(m.buf)
+	r.bufStart = m.bufStart
+	if r.recording {
+		r.consumed = r.consumed[:max(min(m.nread-r.consumedBase, len(r.consumed)), 0)]
+	}
+	r.err = m.err
+	r.states = slices.Clone(m.states)
+	r.emitted = r.emitted[:min(m.emitted, len(r.emitted))]
+}
+
+func (r *reader) Release(m Mark) {
+	if r.debug {
+		r.Debugf("Release", slog.Int("mark", m.id))
+	}
+	delete(r.marks, m.id)
+	if len(r.marks) == 0 {
+		r.history = nil
+		r.histBase = r.nread
+		return
+	}
+	low := r.nread
+	for _, n := range r.marks {
+		low = min(low, n)
+	}
+	if low > r.histBase {
+		r.history = slices.Clone(r.history[low-r.histBase:])
+		r.histBase = low
+	}
+}
+
+func (r *reader) Try(f func() bool) bool {
+	m := r.Mark()
+	defer r.Release(m)
+	if f() {
+		return true
+	}
+	r.Reset(m)
+	return false
+}
+
+// peekAt gets the i-th rune from the head of the input but keeps the pos.
+func (r *reader) peekAt(i int) (rune, bool) {
+	if err := r.fill(i + 1); err != nil {
+		if !errors.Is(err, io.EOF) {
+			r.Errorf(err, "Peek from reader")
+		}
+		return 0, false
+	}
+	return r.ahead[i], true
+}
+
 func (r *reader) Discard() rune {
-	g, _, err := r.rdr.ReadRune()
-	r.Debugf("Discard", slog.String("rune", string(g)), slog.Any("err", err))
+	g, err := r.readRune()
+	if r.debug {
+		r.Debugf("Discard", slog.String("rune", string(g)), slog.Any("err", err))
+	}
 	if err != nil {
 		if !errors.Is(err, io.EOF) {
 			r.Errorf(err, "Discard from reader")
@@ -119,24 +440,47 @@ func (r *reader) Discard() rune {
 }
 
 func (r *reader) Peek() rune {
-	g, _, err := r.rdr.ReadRune()
-	r.Debugf("Peek", slog.String("rune", string(g)), slog.Any("err", err))
+	var g rune
+	err := r.fill(1)
+	if err == nil {
+		g = r.ahead[0]
+	}
+	if r.debug {
+		r.Debugf("Peek", slog.String("rune", string(g)), slog.Any("err", err))
+	}
 	if err != nil {
 		if !errors.Is(err, io.EOF) {
 			r.Errorf(err, "Peek from reader")
 		}
 		return EOF
 	}
-	if err := r.rdr.UnreadRune(); err != nil {
-		r.Errorf(err, "Peek failed to unread")
-		return EOF
-	}
 	return g
 }
 
+func (r *reader) PeekN(k int) []rune {
+	err := r.fill(k)
+	n := max(min(k, len(r.ahead)), 0)
+	if r.debug {
+		r.Debugf("PeekN", slog.Int("k", k), slog.String("runes", string(r.ahead[:n])), slog.Any("err", err))
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		r.Errorf(err, "PeekN from reader")
+	}
+	return slices.Clone(r.ahead[:n])
+}
+
+func (r *reader) PeekString(n int) string { return string(r.PeekN(n)) }
+
+func (r *reader) HasPrefix(s string) bool {
+	want := []rune(s)
+	return slices.Equal(want, r.PeekN(len(want)))
+}
+
 func (r *reader) Next() rune {
-	g, _, err := r.rdr.ReadRune()
-	r.Debugf("Next", slog.String("rune", string(g)), slog.Any("err", err))
+	g, err := r.readRune()
+	if r.debug {
+		r.Debugf("Next", slog.String("rune", string(g)), slog.Any("err", err))
+	}
 	if err != nil {
 		if !errors.Is(err, io.EOF) {
 			r.Errorf(err, "Next from reader")
@@ -144,6 +488,9 @@ func (r *reader) Next() rune {
 		return EOF
 	}
 
+	if r.buf.Len() == 0 {
+		r.bufStart = r.pos
+	}
 	r.pos = r.pos.Add(g)
 	if _, err := r.buf.WriteRune(g); err != nil {
 		r.Errorf(err, "Next failed to write buffer")
@@ -154,6 +501,33 @@ func (r *reader) Next() rune {
 
 func (r *reader) next() { _ = r.Next() }
 
+func (r *reader) PushState(state string) {
+	r.states = append(r.states, state)
+	r.Debugf("PushState")
+}
+
+func (r *reader) PopState() string {
+	if len(r.states) == 0 {
+		r.Errorf(ErrUnbalancedState, "PopState from "+InitialState)
+		return ""
+	}
+	state := r.states[len(r.states)-1]
+	r.states = r.states[:len(r.states)-1]
+	if r.debug {
+		r.Debugf("PopState", slog.String("popped", state))
+	}
+	return state
+}
+
+func (r reader) CurrentState() string {
+	if len(r.states) == 0 {
+		return InitialState
+	}
+	return r.states[len(r.states)-1]
+}
+
+func (r reader) States() []string { return append([]string{}, r.states...) }
+
 // ScanFunc scans source and calculate token.
 type ScanFunc func(Reader) int
 
@@ -176,9 +550,32 @@ func NewScanner(rdr Reader, scanFunc ScanFunc) Scanner {
 	}
 }
 
+// NewScannerWithStates returns a Scanner that calls the ScanFunc of the current lexer state.
+//
+// If the ScanFunc changes the state stack and returns EOF, the ScanFunc of the current state is called,
+// e.g. after pushing the state it is already in.
+// Sets ErrUnknownState if no ScanFunc is registered for the current state.
+func NewScannerWithStates(rdr Reader, scanFuncs map[string]ScanFunc) Scanner {
+	return NewScanner(rdr, func(r Reader) int {
+		for {
+			states := r.States()
+			state := r.CurrentState()
+			f, ok := scanFuncs[state]
+			if !ok {
+				r.Errorf(ErrUnknownState, fmt.Sprintf("no ScanFunc for %s", state))
+				return EOF
+			}
+			t := f(r)
+			if t != EOF || r.Err() != nil || slices.Equal(r.States(), states) {
+				return t
+			}
+		}
+	})
+}
+
 func (s *scanner) Scan() int { return s.scanFunc(s.Reader) }
 func (s *scanner) Error(msg string) {
-	s.Errorf(fmt.Errorf("%w: %s", ErrYbase, msg), msg)
+	s.Errorf(ErrSyntax, msg)
 }
 
 // Lexer is an utility to implement yyLexer.
@@ -198,49 +595,314 @@ func (s *scanner) Error(msg string) {
 //	    lval.token = tok  // declares in %union
 //	  })
 //	}
+//
+// or use NewYaccLexer.
 type Lexer interface {
 	Scanner
 	// DoLex runs the lexical analysis.
+	// Returns the tokens queued by Reader.Emit before calling ScanFunc again.
 	// Returns EOF if EOF or an error occurs.
+	// Returns ErrorToken on an error if the recovery is enabled, see WithRecovery.
 	DoLex(callback func(Token)) int
+	// Diagnostics returns the errors recovered from.
+	Diagnostics() []error
+	// All returns an iterator over the tokens.
+	// The last pair is (nil, err) if an error occurred.
+	All() iter.Seq2[Token, error]
+	// EOFTrivia returns the discarded text after the trailing trivia of the last token
+	// in the lossless mode, see WithLossless.
+	EOFTrivia() string
 }
 
 type lexer struct {
 	Scanner
-	pos Pos
+	pos         Pos
+	last        Token
+	registry    *Registry
+	recovery    bool
+	sync        func(rune) bool
+	diagnostics []error
+	queue       []Token // tokens to return before scanning
+
+	lossless  bool
+	lastToken *token // last token waiting for the trailing trivia
+	consumed  string // consumed runes after pos
+	drain     bool   // deliver the queue despite the error
+	eofTrivia string
 }
 
-func NewLexer(scanner Scanner) Lexer {
-	return &lexer{
+// LexerOption configures Lexer.
+type LexerOption func(*lexer)
+
+// WithRecovery enables the error recovery.
+//
+// On an error, the lexer appends the error to Diagnostics,
+// skips runes until sync reports true for the next rune, at least one rune,
+// and returns ErrorToken whose value is the skipped runes.
+// If sync is nil, the lexer skips one rune.
+func WithRecovery(sync func(rune) bool) LexerOption {
+	return func(l *lexer) {
+		l.recovery = true
+		l.sync = sync
+	}
+}
+
+// WithLossless enables the lossless mode.
+//
+// The lexer records the discarded runes as the trivia of the tokens
+// and the start of a token is the first rune of the value,
+// not the end of the previous token as in the default mode:
+// the trailing trivia of a token is the discarded runes after it up to and including the first newline,
+// and the leading trivia of the next token is the rest.
+// The discarded runes after the trailing trivia of the last token are EOFTrivia.
+//
+// Concatenating the leading trivia, the value and the trailing trivia of the tokens and EOFTrivia
+// reproduces the input if ScanFunc discards runes only before the tokens, i.e. not between Next calls,
+// and the input is valid UTF-8.
+// The tokens queued by Reader.Emit have the trivia around their spans like the scanned tokens,
+// so the input is reproduced if the values of them are the runes of the spans,
+// e.g. > and > split from >>, or an empty value of an empty span.
+//
+// The trailing trivia depends on the runes ScanFunc discards,
+// so the lexer scans the next token before returning a token, one token ahead of the default mode:
+// the state changes between DoLex calls, e.g. PushState by the parser, take effect one token later,
+// and DoLex reads the input up to the end of the next token, that blocks on an interactive input.
+func WithLossless() LexerOption {
+	return func(l *lexer) {
+		l.lossless = true
+	}
+}
+
+// WithRegistry names the tokens by the registry.
+func WithRegistry(reg *Registry) LexerOption {
+	return func(l *lexer) {
+		l.registry = reg
+	}
+}
+
+func NewLexer(scanner Scanner, opts ...LexerOption) Lexer {
+	l := &lexer{
 		Scanner: scanner,
 		pos:     scanner.Pos(),
 	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.lossless {
+		// start recording
+		_ = l.TakeConsumed()
+	}
+	return l
+}
+
+func (l *lexer) Diagnostics() []error { return l.diagnostics }
+func (l *lexer) EOFTrivia() string    { return l.eofTrivia }
+
+// Error sets a *LexError wrapping a *SyntaxError at the first rune of the last token.
+func (l *lexer) Error(msg string) {
+	e := NewSyntaxError(msg, l.last, l.Pos(), nil)
+	l.ErrorfAt(e.Start, e, e.Message())
+}
+
+func (l *lexer) All() iter.Seq2[Token, error] { return all(l.DoLex, l.Err) }
+
+// all returns an iterator over the tokens from doLex, see Lexer.All.
+func all(doLex func(func(Token)) int, errFunc func() error) iter.Seq2[Token, error] {
+	return func(yield func(Token, error) bool) {
+		for {
+			var tok Token
+			if doLex(func(t Token) { tok = t }) == EOF {
+				if err := errFunc(); err != nil {
+					_ = yield(nil, err)
+				}
+				return
+			}
+			if !yield(tok, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Tokens reads all the tokens from the lexer.
+func Tokens(lexer Lexer) ([]Token, error) {
+	toks := []Token{}
+	for tok, err := range lexer.All() {
+		if err != nil {
+			return toks, err
+		}
+		toks = append(toks, tok)
+	}
+	return toks, nil
 }
 
 func (l *lexer) DoLex(callback func(Token)) int {
 	if l.Err() != nil {
+		if !l.recovery {
+			if l.drain && len(l.queue) > 0 {
+				return l.deliver(callback)
+			}
+			return EOF
+		}
+		// e.g. Error by the parser
+		l.diagnostics = append(l.diagnostics, l.Err())
+		l.ResetErr()
+	}
+	for l.ready() == 0 && l.lex() {
+	}
+	if len(l.queue) == 0 {
 		return EOF
 	}
-	start := l.pos
+	return l.deliver(callback)
+}
+
+// ready returns the number of the queued tokens to deliver.
+// In the lossless mode, the last token and the following tokens wait for the trailing trivia of the last token.
+func (l *lexer) ready() int {
+	if l.lastToken == nil {
+		return len(l.queue)
+	}
+	if i := slices.Index(l.queue, Token(l.lastToken)); i >= 0 {
+		return i
+	}
+	return len(l.queue)
+}
+
+// lex scans and queues the tokens.
+// Returns false if no more tokens are queued.
+func (l *lexer) lex() bool {
 	t := l.Scan()
-	if t == EOF || l.Err() != nil {
-		return EOF
+	if l.Err() != nil {
+		_ = l.TakeEmitted()
+		if !l.recovery {
+			// deliver the tokens before the error
+			l.lastToken = nil
+			l.drain = true
+			return false
+		}
+		return l.recover()
 	}
-	end := l.Pos()
-	l.pos = end
-	v := l.Buffer()
-	tok := NewToken(t, v, start, end)
-	callback(tok)
-	l.Debugf("Lex",
-		slog.Int("type", tok.Type()),
-		slog.String("value", tok.Value()),
-		slog.Int("start.line", tok.Start().Line()),
-		slog.Int("start.column", tok.Start().Column()),
-		slog.Int("start.offset", tok.Start().Offset()),
-		slog.Int("end.line", tok.End().Line()),
-		slog.Int("end.column", tok.End().Column()),
-		slog.Int("end.offset", tok.End().Offset()),
-	)
+	emitted := l.TakeEmitted()
+	for _, x := range emitted {
+		tok := NewTokenWithRegistry(x.Type, x.Value, x.Span.Start, x.Span.End, l.registry).(*token)
+		if l.lossless {
+			l.attach(tok)
+		}
+		l.queue = append(l.queue, tok)
+	}
+	if t == EOF {
+		if len(emitted) == 0 {
+			l.eof()
+			return false
+		}
+		// the tokens replaced the buffer
+		if !l.lossless {
+			l.pos = l.Pos()
+		}
+		l.ResetBuffer()
+		return true
+	}
+	l.emit(t)
+	return true
+}
+
+func (l *lexer) recover() bool {
+	l.diagnostics = append(l.diagnostics, l.Err())
+	l.ResetErr()
+	if l.Buffer() == "" && l.Next() == EOF {
+		l.eof()
+		return false
+	}
+	if l.sync != nil {
+		l.NextWhile(func(x rune) bool { return x != EOF && !l.sync(x) })
+	}
+	l.emit(ErrorToken)
+	return true
+}
+
+// emit queues the token of the buffer.
+func (l *lexer) emit(t int) {
+	tok := &token{
+		t:      t,
+		v:      l.Buffer(),
+		start:  l.BufferStart(),
+		end:    l.Pos(),
+		reg:    l.registry,
+		vstart: l.BufferStart(),
+	}
+	if l.lossless {
+		l.attach(tok)
+	} else {
+		// the token includes the discarded runes before it
+		tok.start = l.pos
+	}
+	tok.states = l.States()
+	l.pos = tok.end
+	l.queue = append(l.queue, tok)
 	l.ResetBuffer()
+}
+
+func (l *lexer) eof() {
+	if !l.lossless {
+		return
+	}
+	l.eofTrivia += l.trivia(l.skip(l.Pos()))
+	l.lastToken = nil
+}
+
+// attach sets the trivia before the token and makes the token the last token.
+func (l *lexer) attach(tok *token) {
+	tok.leading = l.trivia(l.skip(tok.start))
+	// the runes of the token are not trivia
+	_ = l.skip(tok.end)
+	l.lastToken = tok
+}
+
+// skip returns the consumed runes before p and advances pos to p.
+func (l *lexer) skip(p Pos) string {
+	l.consumed += l.TakeConsumed()
+	n := p.Offset() - l.pos.Offset()
+	if n <= 0 {
+		return ""
+	}
+	n = min(n, len(l.consumed))
+	x := l.consumed[:n]
+	l.consumed = l.consumed[n:]
+	l.pos = p
+	return x
+}
+
+// trivia sets the trailing trivia of the last token from the gap and returns the rest.
+func (l *lexer) trivia(gap string) string {
+	if l.lastToken == nil {
+		return gap
+	}
+	i := strings.IndexByte(gap, '\n') + 1
+	if i == 0 {
+		i = len(gap)
+	}
+	l.lastToken.trailing = gap[:i]
+	l.lastToken = nil
+	return gap[i:]
+}
+
+func (l *lexer) deliver(callback func(Token)) int {
+	tok := l.queue[0]
+	l.queue = l.queue[1:]
+	l.last = tok
+	callback(tok)
+	if l.DebugEnabled() {
+		l.Debugf("Lex",
+			slog.Int("type", tok.Type()),
+			slog.String("name", tok.Kind().Name),
+			slog.String("value", tok.Value()),
+			slog.Int("start.line", tok.Start().Line()),
+			slog.Int("start.column", tok.Start().Column()),
+			slog.Int("start.offset", tok.Start().Offset()),
+			slog.Int("end.line", tok.End().Line()),
+			slog.Int("end.column", tok.End().Column()),
+			slog.Int("end.offset", tok.End().Offset()),
+		)
+	}
 	return tok.Type()
 }
@@ -2,9 +2,13 @@ package ybase_test
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"log/slog"
+	"regexp"
 	"strings"
 	"testing"
+	"testing/iotest"
 	"unicode"
 
 	"github.com/berquerant/ybase"
@@ -53,6 +57,151 @@ func TestReader(t *testing.T) {
 	t.Run("final next", assertResult(nil, ybase.EOF, reader.Next(), "---"))
 }
 
+func TestReaderDebug(t *testing.T) {
+	var msgs []string
+	r := ybase.NewReader(bytes.NewBufferString("ab"), func(msg string, _ ...any) { msgs = append(msgs, msg) })
+	assert.True(t, r.DebugEnabled())
+	_ = r.PeekN(2)
+	m := r.Mark()
+	_ = r.Next()
+	r.Reset(m)
+	r.Release(m)
+	assert.Equal(t, []string{"ybase: PeekN", "ybase: Mark", "ybase: Next", "ybase: Reset", "ybase: Release"}, msgs)
+
+	assert.False(t, ybase.NewReader(bytes.NewBufferString("ab"), nil).DebugEnabled())
+}
+
+func TestReaderLookahead(t *testing.T) {
+	for _, tc := range []struct {
+		title  string
+		reader func(string) ybase.Reader
+	}{
+		{
+			title:  "buffer",
+			reader: newReader,
+		},
+		{
+			title: "one byte reader",
+			reader: func(input string) ybase.Reader {
+				return ybase.NewReader(iotest.OneByteReader(bytes.NewBufferString(input)), slog.Info)
+			},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			reader := tc.reader("<<=あい")
+
+			assert.Equal(t, []rune("<<="), reader.PeekN(3))
+			assert.Equal(t, "<<=あ", reader.PeekString(4))
+			assert.True(t, reader.HasPrefix("<<"))
+			assert.False(t, reader.HasPrefix("<="))
+			assert.Equal(t, 0, reader.Pos().Offset(), "pos")
+			assert.Equal(t, "", reader.Buffer(), "buf")
+
+			assert.Equal(t, '<', reader.Next())
+			assert.Equal(t, "<=あい", reader.PeekString(10))
+			assert.Equal(t, []rune{}, reader.PeekN(0))
+			reader.DiscardWhile(func(r rune) bool { return r == '<' || r == '=' })
+			assert.True(t, reader.HasPrefix("あい"))
+			assert.False(t, reader.HasPrefix("あいう"))
+			assert.Equal(t, 'あ', reader.Next())
+			assert.Equal(t, 'い', reader.Next())
+			assert.Equal(t, rune(ybase.EOF), reader.Peek())
+			assert.Equal(t, "", reader.PeekString(2))
+			assert.Equal(t, "<あい", reader.Buffer(), "buf")
+			assert.Equal(t, 9, reader.Pos().Offset(), "pos")
+			assert.Nil(t, reader.Err())
+		})
+	}
+}
+
+func TestReaderMark(t *testing.T) {
+	t.Run("reset", func(t *testing.T) {
+		reader := newReader("12.5e")
+		_ = reader.Next()
+		m := reader.Mark()
+		reader.NextWhile(func(r rune) bool { return r != 'e' })
+		assert.Equal(t, "12.5", reader.Buffer())
+		assert.Equal(t, 4, reader.Pos().Offset())
+
+		reader.Reset(m)
+		assert.Equal(t, "1", reader.Buffer())
+		assert.Equal(t, 1, reader.Pos().Offset())
+		assert.Equal(t, "2.5e", reader.PeekString(10))
+
+		_ = reader.Discard()
+		reader.Reset(m)
+		assert.Equal(t, '2', reader.Next())
+		reader.Release(m)
+		assert.Equal(t, ".5e", reader.PeekString(10))
+		assert.Nil(t, reader.Err())
+
+		reader.Reset(m)
+		assert.ErrorIs(t, reader.Err(), ybase.ErrInvalidMark)
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		reader := newReader("abcdef")
+		outer := reader.Mark()
+		_ = reader.Next()
+		inner := reader.Mark()
+		_ = reader.Next()
+		_ = reader.Next()
+		reader.Reset(inner)
+		reader.Release(inner)
+		assert.Equal(t, "a", reader.Buffer())
+		_ = reader.Next()
+		reader.Reset(outer)
+		reader.Release(outer)
+		assert.Equal(t, "", reader.Buffer())
+		assert.Equal(t, "abcdef", reader.PeekString(10))
+	})
+
+	t.Run("reset forward", func(t *testing.T) {
+		reader := newReader("abc")
+		outer := reader.Mark()
+		_ = reader.Next()
+		inner := reader.Mark()
+		_ = reader.Next()
+		reader.Reset(outer)
+		assert.Equal(t, "", reader.Buffer())
+		reader.Reset(inner)
+		assert.Nil(t, reader.Err())
+		assert.Equal(t, "a", reader.Buffer())
+		assert.Equal(t, 1, reader.Pos().Offset())
+		assert.Equal(t, "bc", reader.PeekString(10))
+		assert.Equal(t, 'b', reader.Next())
+
+		reader.Reset(outer)
+		reader.Release(outer)
+		reader.Release(inner)
+		assert.Equal(t, "abc", reader.PeekString(10))
+	})
+
+	t.Run("try", func(t *testing.T) {
+		// float literal or integer followed by dot
+		reader := newReader("12.x")
+		reader.NextWhile(unicode.IsDigit)
+		ok := reader.Try(func() bool {
+			if reader.Next() != '.' {
+				return false
+			}
+			if !unicode.IsDigit(reader.Peek()) {
+				return false
+			}
+			reader.NextWhile(unicode.IsDigit)
+			return true
+		})
+		assert.False(t, ok)
+		assert.Equal(t, "12", reader.Buffer())
+		assert.Equal(t, 2, reader.Pos().Offset())
+		assert.Equal(t, '.', reader.Peek())
+
+		assert.True(t, reader.Try(func() bool { return reader.Next() == '.' }))
+		assert.Equal(t, "12.", reader.Buffer())
+		assert.Nil(t, reader.Err())
+	})
+}
+
 func newTokens(v ...any) []ybase.Token {
 	var toks []ybase.Token
 	for i := 0; i < len(v); i++ {
@@ -64,6 +213,41 @@ func newTokens(v ...any) []ybase.Token {
 	return toks
 }
 
+func TestReaderError(t *testing.T) {
+	t.Run("first error is kept", func(t *testing.T) {
+		r := newReader("")
+		r.Errorf(ybase.ErrSyntax, "first")
+		r.Errorf(ybase.ErrNoRuleMatched, "second")
+		assert.ErrorIs(t, r.Err(), ybase.ErrSyntax)
+		r.ResetErr()
+		assert.Nil(t, r.Err())
+	})
+
+	t.Run("ErrorfAt", func(t *testing.T) {
+		r := newReader("abc")
+		_ = r.Next()
+		p := r.Pos()
+		_ = r.Next()
+		r.ErrorfAt(p, ybase.ErrSyntax, "first")
+		r.ErrorfAt(r.Pos(), ybase.ErrNoRuleMatched, "second")
+		var e *ybase.LexError
+		if !assert.True(t, errors.As(r.Err(), &e)) {
+			return
+		}
+		assert.ErrorIs(t, e, ybase.ErrSyntax)
+		assert.Equal(t, 1, e.Pos.Offset())
+		assert.Equal(t, rune(ybase.EOF), e.Rune)
+		assert.Equal(t, "1:2: first: Syntax", e.Error())
+
+		r.ResetErr()
+		assert.Equal(t, 'c', r.Peek())
+		r.ErrorfAt(r.Pos(), ybase.ErrSyntax, "here")
+		if assert.True(t, errors.As(r.Err(), &e)) {
+			assert.Equal(t, 'c', e.Rune)
+		}
+	})
+}
+
 func TestLexer(t *testing.T) {
 	for _, tc := range []struct {
 		title string
@@ -167,3 +351,645 @@ func TestLexer(t *testing.T) {
 		})
 	}
 }
+
+func TestScannerWithStates(t *testing.T) {
+	const (
+		tText = iota + 1
+		tStrStart
+		tStrEnd
+		tStr
+	)
+	// string interpolation, e.g. "a${"b"}c"
+	newScanner := func(input string) ybase.Scanner {
+		return ybase.NewScannerWithStates(newReader(input), map[string]ybase.ScanFunc{
+			ybase.InitialState: func(r ybase.Reader) int {
+				switch r.Peek() {
+				case ybase.EOF:
+					return ybase.EOF
+				case '"':
+					_ = r.Next()
+					r.PushState("STR")
+					return tStrStart
+				case '}':
+					_ = r.Discard()
+					_ = r.PopState()
+					return ybase.EOF
+				default:
+					r.NextWhile(func(x rune) bool { return x != '"' && x != '}' && x != ybase.EOF })
+					return tText
+				}
+			},
+			"STR": func(r ybase.Reader) int {
+				switch {
+				case r.Peek() == ybase.EOF:
+					return ybase.EOF
+				case r.Peek() == '"':
+					_ = r.Next()
+					_ = r.PopState()
+					return tStrEnd
+				case r.HasPrefix("${"):
+					_ = r.Discard()
+					_ = r.Discard()
+					r.PushState(ybase.InitialState)
+					return ybase.EOF
+				default:
+					r.NextWhile(func(x rune) bool { return x != '"' && x != '$' && x != ybase.EOF })
+					return tStr
+				}
+			},
+		})
+	}
+
+	t.Run("interpolation", func(t *testing.T) {
+		s := ybase.NewLexer(newScanner(`x"a${y"b"}c"`))
+		got := []ybase.Token{}
+		for s.DoLex(func(tok ybase.Token) { got = append(got, tok) }) != ybase.EOF {
+		}
+		assert.Nil(t, s.Err())
+		want := newTokens(
+			tText, "x",
+			tStrStart, `"`,
+			tStr, "a",
+			tText, "y",
+			tStrStart, `"`,
+			tStr, "b",
+			tStrEnd, `"`,
+			tStr, "c",
+			tStrEnd, `"`,
+		)
+		assert.Equal(t, len(want), len(got))
+		for i, w := range want {
+			assert.Equal(t, w.Type(), got[i].Type(), i)
+			assert.Equal(t, w.Value(), got[i].Value(), i)
+		}
+		assert.Equal(t, ybase.InitialState, s.CurrentState())
+	})
+
+	t.Run("nested comments", func(t *testing.T) {
+		const tIdent = 1
+		s := ybase.NewLexer(ybase.NewScannerWithStates(newReader("/* a /* b */ c */ x"), map[string]ybase.ScanFunc{
+			ybase.InitialState: func(r ybase.Reader) int {
+				r.DiscardWhile(unicode.IsSpace)
+				switch {
+				case r.Peek() == ybase.EOF:
+					return ybase.EOF
+				case r.HasPrefix("/*"):
+					_, _ = r.Discard(), r.Discard()
+					r.PushState("COMMENT")
+					return ybase.EOF
+				}
+				r.NextWhile(unicode.IsLetter)
+				return tIdent
+			},
+			"COMMENT": func(r ybase.Reader) int {
+				for {
+					switch {
+					case r.Peek() == ybase.EOF:
+						return ybase.EOF
+					case r.HasPrefix("/*"):
+						_, _ = r.Discard(), r.Discard()
+						// push the current state
+						r.PushState("COMMENT")
+						return ybase.EOF
+					case r.HasPrefix("*/"):
+						_, _ = r.Discard(), r.Discard()
+						_ = r.PopState()
+						return ybase.EOF
+					}
+					_ = r.Discard()
+				}
+			},
+		}))
+		tokens, err := ybase.Tokens(s)
+		assert.Nil(t, err)
+		if assert.Equal(t, 1, len(tokens)) {
+			assert.Equal(t, tIdent, tokens[0].Type())
+			assert.Equal(t, "x", tokens[0].Value())
+		}
+		assert.Equal(t, ybase.InitialState, s.CurrentState())
+	})
+
+	t.Run("unbalanced", func(t *testing.T) {
+		s := ybase.NewLexer(newScanner("x}"))
+		for s.DoLex(func(ybase.Token) {}) != ybase.EOF {
+		}
+		assert.ErrorIs(t, s.Err(), ybase.ErrUnbalancedState)
+		assert.ErrorContains(t, s.Err(), "1:3: ")
+	})
+
+	t.Run("unknown state", func(t *testing.T) {
+		r := newReader("x")
+		r.PushState("UNKNOWN")
+		s := ybase.NewScannerWithStates(r, map[string]ybase.ScanFunc{})
+		assert.Equal(t, ybase.EOF, s.Scan())
+		assert.ErrorIs(t, s.Err(), ybase.ErrUnknownState)
+	})
+
+	t.Run("mark", func(t *testing.T) {
+		r := newReader("x")
+		m := r.Mark()
+		r.PushState("A")
+		r.PushState("B")
+		assert.Equal(t, "B", r.CurrentState())
+		r.Reset(m)
+		r.Release(m)
+		assert.Equal(t, ybase.InitialState, r.CurrentState())
+	})
+}
+
+func TestLexerRecovery(t *testing.T) {
+	scan := ybase.NewRuleScanFunc(
+		ybase.Rule{Pattern: ybase.Runes(unicode.IsSpace), Skip: true},
+		ybase.Rule{Type: 1, Pattern: ybase.Runes(unicode.IsLetter)},
+		ybase.Rule{Type: 10, Pattern: ybase.Runes(unicode.IsDigit)},
+	)
+
+	for _, tc := range []struct {
+		title       string
+		input       string
+		sync        func(rune) bool
+		want        []ybase.Token
+		diagnostics int
+	}{
+		{
+			title: "skip one rune",
+			input: "ab ?? cd !x 12",
+			want: newTokens(
+				1, "ab",
+				ybase.ErrorToken, "?",
+				ybase.ErrorToken, "?",
+				1, "cd",
+				ybase.ErrorToken, "!",
+				1, "x",
+				10, "12",
+			),
+			diagnostics: 3,
+		},
+		{
+			title: "sync by space",
+			input: "ab ?? cd !x 12",
+			sync:  unicode.IsSpace,
+			want: newTokens(
+				1, "ab",
+				ybase.ErrorToken, "??",
+				1, "cd",
+				ybase.ErrorToken, "!x",
+				10, "12",
+			),
+			diagnostics: 2,
+		},
+		{
+			title: "error at last",
+			input: "ab ?",
+			want: newTokens(
+				1, "ab",
+				ybase.ErrorToken, "?",
+			),
+			diagnostics: 1,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			s := ybase.NewLexer(ybase.NewScanner(newReader(tc.input), scan), ybase.WithRecovery(tc.sync))
+			got := []ybase.Token{}
+			for s.DoLex(func(tok ybase.Token) { got = append(got, tok) }) != ybase.EOF {
+			}
+			assert.Nil(t, s.Err())
+			assert.Equal(t, tc.diagnostics, len(s.Diagnostics()))
+			for _, err := range s.Diagnostics() {
+				assert.ErrorIs(t, err, ybase.ErrNoRuleMatched)
+			}
+			assert.Equal(t, len(tc.want), len(got))
+			for i, w := range tc.want {
+				g := got[i]
+				assert.Equal(t, w.Type(), g.Type(), i)
+				assert.Equal(t, w.Value(), g.Value(), i)
+			}
+		})
+	}
+
+	t.Run("parser error", func(t *testing.T) {
+		s := ybase.NewLexer(ybase.NewScanner(newReader("ab cd"), scan), ybase.WithRecovery(nil))
+		assert.Equal(t, 1, s.DoLex(func(ybase.Token) {}))
+		s.Error("syntax error")
+		assert.Equal(t, 1, s.DoLex(func(ybase.Token) {}))
+		assert.Equal(t, ybase.EOF, s.DoLex(func(ybase.Token) {}))
+		if assert.Equal(t, 1, len(s.Diagnostics())) {
+			assert.ErrorIs(t, s.Diagnostics()[0], ybase.ErrSyntax)
+		}
+	})
+}
+
+func TestLexerAll(t *testing.T) {
+	scan := ybase.NewRuleScanFunc(
+		ybase.Rule{Pattern: ybase.Runes(unicode.IsSpace), Skip: true},
+		ybase.Rule{Type: 1, Pattern: ybase.Runes(unicode.IsLetter)},
+	)
+	newLexer := func(input string) ybase.Lexer {
+		return ybase.NewLexer(ybase.NewScanner(newReader(input), scan))
+	}
+
+	t.Run("all", func(t *testing.T) {
+		var got []string
+		for tok, err := range newLexer("ab cd ef").All() {
+			assert.Nil(t, err)
+			got = append(got, tok.Value())
+		}
+		assert.Equal(t, []string{"ab", "cd", "ef"}, got)
+	})
+
+	t.Run("break", func(t *testing.T) {
+		lexer := newLexer("ab cd ef")
+		for tok := range lexer.All() {
+			assert.Equal(t, "ab", tok.Value())
+			break
+		}
+		toks, err := ybase.Tokens(lexer)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(toks))
+		assert.Equal(t, "cd", toks[0].Value())
+	})
+
+	t.Run("error", func(t *testing.T) {
+		var (
+			got  []string
+			errs []error
+		)
+		for tok, err := range newLexer("ab ? cd").All() {
+			if err != nil {
+				errs = append(errs, err)
+				assert.Nil(t, tok)
+				continue
+			}
+			got = append(got, tok.Value())
+		}
+		assert.Equal(t, []string{"ab"}, got)
+		if assert.Equal(t, 1, len(errs)) {
+			assert.ErrorIs(t, errs[0], ybase.ErrNoRuleMatched)
+		}
+
+		toks, err := ybase.Tokens(newLexer("ab ? cd"))
+		assert.ErrorIs(t, err, ybase.ErrNoRuleMatched)
+		assert.Equal(t, 1, len(toks))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		toks, err := ybase.Tokens(newLexer(""))
+		assert.Nil(t, err)
+		assert.Equal(t, []ybase.Token{}, toks)
+	})
+}
+
+func TestReaderWithPosConfig(t *testing.T) {
+	r := ybase.NewReader(bytes.NewBufferString("a\r\n\tb"), nil, ybase.WithPosConfig(ybase.PosConfig{
+		Newline:  ybase.NewlineCRLF,
+		TabWidth: 4,
+	}))
+	for r.Next() != 'b' {
+	}
+	assert.Equal(t, 2, r.Pos().Line())
+	assert.Equal(t, 5, r.Pos().Column())
+	assert.Equal(t, 5, r.Pos().Offset())
+}
+
+func TestReaderStates(t *testing.T) {
+	r := ybase.NewReader(bytes.NewBufferString(""), nil, ybase.WithStates([]string{"A", "B"}))
+	assert.Equal(t, "B", r.CurrentState())
+	r.PushState("C")
+	states := r.States()
+	assert.Equal(t, []string{"A", "B", "C"}, states)
+	states[0] = "X"
+	assert.Equal(t, "C", r.PopState())
+	assert.Equal(t, []string{"A", "B"}, r.States())
+	assert.Equal(t, []string{}, ybase.NewReader(bytes.NewBufferString(""), nil).States())
+}
+
+func TestLexerEmit(t *testing.T) {
+	const IDENT = 1
+	// type value start-end
+	dump := func(tokens []ybase.Token) []string {
+		xs := make([]string, len(tokens))
+		for i, x := range tokens {
+			xs[i] = fmt.Sprintf("%d %q %d-%d", x.Type(), x.Value(), x.Start().Offset(), x.End().Offset())
+		}
+		return xs
+	}
+
+	for _, tc := range []struct {
+		title string
+		input string
+		scan  ybase.ScanFunc
+		want  []string
+		err   error
+	}{
+		{
+			title: "split",
+			input: "a>>",
+			scan: func(r ybase.Reader) int {
+				switch {
+				case r.HasPrefix(">>"):
+					p := r.Pos()
+					_ = r.Next()
+					q := r.Pos()
+					_ = r.Next()
+					r.Emit('>', ">", ybase.NewSpan(p, q))
+					r.Emit('>', ">", ybase.NewSpan(q, r.Pos()))
+					return ybase.EOF
+				case unicode.IsLetter(r.Peek()):
+					r.NextWhile(unicode.IsLetter)
+					return IDENT
+				}
+				return ybase.EOF
+			},
+			want: []string{
+				`1 "a" 0-1`,
+				`62 ">" 1-2`,
+				`62 ">" 2-3`,
+			},
+		},
+		{
+			title: "virtual semicolons",
+			input: "a\nb\n",
+			scan: func(r ybase.Reader) int {
+				for r.Peek() == '\n' {
+					r.Emit(';', "", ybase.Span{})
+					_ = r.Discard()
+				}
+				if !unicode.IsLetter(r.Peek()) {
+					return ybase.EOF
+				}
+				r.NextWhile(unicode.IsLetter)
+				return IDENT
+			},
+			want: []string{
+				`1 "a" 0-1`,
+				`59 "" 1-1`,
+				`1 "b" 1-3`,
+				`59 "" 3-3`,
+			},
+		},
+		{
+			title: "emitted before scanned",
+			input: "ab",
+			scan: func(r ybase.Reader) int {
+				if r.Peek() == ybase.EOF {
+					return ybase.EOF
+				}
+				r.Emit('^', "", ybase.Span{})
+				_ = r.Next()
+				return IDENT
+			},
+			want: []string{
+				`94 "" 0-0`,
+				`1 "a" 0-1`,
+				`94 "" 1-1`,
+				`1 "b" 1-2`,
+			},
+		},
+		{
+			title: "reset drops emitted",
+			input: "ab",
+			scan: func(r ybase.Reader) int {
+				_ = r.Try(func() bool {
+					r.Emit('^', "", ybase.Span{})
+					return false
+				})
+				if r.Next() == ybase.EOF {
+					return ybase.EOF
+				}
+				return IDENT
+			},
+			want: []string{
+				`1 "a" 0-1`,
+				`1 "b" 1-2`,
+			},
+		},
+		{
+			title: "error drops emitted",
+			input: "a?",
+			scan: func(r ybase.Reader) int {
+				r.Emit('^', "", ybase.Span{})
+				if r.Next() == '?' {
+					r.Errorf(ybase.ErrSyntax, "unexpected")
+				}
+				return IDENT
+			},
+			want: []string{
+				`94 "" 0-0`,
+				`1 "a" 0-1`,
+			},
+			err: ybase.ErrSyntax,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			tokens, err := ybase.Tokens(ybase.NewLexer(ybase.NewScanner(newReader(tc.input), tc.scan)))
+			assert.Equal(t, tc.want, dump(tokens))
+			if tc.err == nil {
+				assert.Nil(t, err)
+			} else {
+				assert.ErrorIs(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestLexerLossless(t *testing.T) {
+	const (
+		IDENT = iota + 1
+		NUM
+	)
+	scan := ybase.NewRuleScanFunc(
+		ybase.Rule{Pattern: ybase.Runes(unicode.IsSpace), Skip: true},
+		ybase.Rule{Pattern: ybase.Regexp(regexp.MustCompile(`#[^\n]*`)), Skip: true},
+		ybase.Rule{Type: IDENT, Pattern: ybase.Runes(unicode.IsLetter)},
+		ybase.Rule{Type: NUM, Pattern: ybase.Runes(unicode.IsDigit)},
+	)
+	// leading value trailing start-end
+	dump := func(tokens []ybase.Token) []string {
+		xs := make([]string, len(tokens))
+		for i, x := range tokens {
+			xs[i] = fmt.Sprintf("%q %q %q %d-%d",
+				x.LeadingTrivia(), x.Value(), x.TrailingTrivia(), x.Start().Offset(), x.End().Offset())
+		}
+		return xs
+	}
+	reconstruct := func(tokens []ybase.Token, eof string) string {
+		var b strings.Builder
+		for _, x := range tokens {
+			b.WriteString(x.LeadingTrivia())
+			b.WriteString(x.Value())
+			b.WriteString(x.TrailingTrivia())
+		}
+		b.WriteString(eof)
+		return b.String()
+	}
+
+	for _, tc := range []struct {
+		title string
+		input string
+		opts  []ybase.LexerOption
+		want  []string
+		eof   string
+		err   error
+	}{
+		{
+			title: "empty",
+			input: "",
+			want:  []string{},
+		},
+		{
+			title: "only trivia",
+			input: "  # c\n",
+			want:  []string{},
+			eof:   "  # c\n",
+		},
+		{
+			title: "no trivia",
+			input: "ab",
+			want: []string{
+				`"" "ab" "" 0-2`,
+			},
+		},
+		{
+			title: "trivia",
+			input: "  # head\nab 12 # tail\n\n  cd\n  # end\n",
+			want: []string{
+				`"  # head\n" "ab" " " 9-11`,
+				`"" "12" " # tail\n" 12-14`,
+				`"\n  " "cd" "\n" 25-27`,
+			},
+			eof: "  # end\n",
+		},
+		{
+			title: "multibyte",
+			input: "\u3000あ\u3000い",
+			want: []string{
+				`"\u3000" "あ" "\u3000" 3-6`,
+				`"" "い" "" 9-12`,
+			},
+		},
+		{
+			title: "recovery",
+			input: "ab ?? 12",
+			opts:  []ybase.LexerOption{ybase.WithRecovery(unicode.IsSpace)},
+			want: []string{
+				`"" "ab" " " 0-2`,
+				`"" "??" " " 3-5`,
+				`"" "12" "" 6-8`,
+			},
+		},
+		{
+			title: "error",
+			input: "ab ? cd",
+			want: []string{
+				`"" "ab" "" 0-2`,
+			},
+			err: ybase.ErrNoRuleMatched,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			lexer := ybase.NewLexer(ybase.NewScanner(newReader(tc.input), scan),
+				append([]ybase.LexerOption{ybase.WithLossless()}, tc.opts...)...)
+			tokens, err := ybase.Tokens(lexer)
+			assert.Equal(t, tc.want, dump(tokens))
+			assert.Equal(t, tc.eof, lexer.EOFTrivia())
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.input, reconstruct(tokens, lexer.EOFTrivia()))
+		})
+	}
+
+	t.Run("state change by the parser", func(t *testing.T) {
+		// the parser pushes UPPER after the first token
+		lex := func(opts ...ybase.LexerOption) []int {
+			lexer := ybase.NewLexer(ybase.NewScannerWithStates(newReader("a b c"), map[string]ybase.ScanFunc{
+				ybase.InitialState: scan,
+				"UPPER": func(r ybase.Reader) int {
+					r.DiscardWhile(unicode.IsSpace)
+					if r.Peek() == ybase.EOF {
+						return ybase.EOF
+					}
+					r.NextWhile(unicode.IsLetter)
+					return NUM
+				},
+			}), opts...)
+			var got []int
+			for x := lexer.DoLex(func(ybase.Token) {}); x != ybase.EOF; x = lexer.DoLex(func(ybase.Token) {}) {
+				if len(got) == 0 {
+					lexer.PushState("UPPER")
+				}
+				got = append(got, x)
+			}
+			return got
+		}
+		assert.Equal(t, []int{IDENT, NUM, NUM}, lex())
+		// b is scanned before the first token is returned
+		assert.Equal(t, []int{IDENT, IDENT, NUM}, lex(ybase.WithLossless()))
+	})
+
+	for _, tc := range []struct {
+		title string
+		input string
+		scan  ybase.ScanFunc
+		want  []string
+	}{
+		{
+			title: "virtual semicolons",
+			input: "a\nb\n",
+			scan: func(r ybase.Reader) int {
+				for r.Peek() == '\n' {
+					r.Emit(';', "", ybase.Span{})
+					_ = r.Discard()
+				}
+				if !unicode.IsLetter(r.Peek()) {
+					return ybase.EOF
+				}
+				r.NextWhile(unicode.IsLetter)
+				return IDENT
+			},
+			want: []string{
+				`"" "a" "" 0-1`,
+				`"" "" "\n" 1-1`,
+				`"" "b" "" 2-3`,
+				`"" "" "\n" 3-3`,
+			},
+		},
+		{
+			title: "split",
+			input: "a >> b\n",
+			scan: func(r ybase.Reader) int {
+				r.DiscardWhile(unicode.IsSpace)
+				switch {
+				case r.HasPrefix(">>"):
+					p := r.Pos()
+					_ = r.Next()
+					q := r.Pos()
+					_ = r.Next()
+					r.Emit('>', ">", ybase.NewSpan(p, q))
+					r.Emit('>', ">", ybase.NewSpan(q, r.Pos()))
+					return ybase.EOF
+				case unicode.IsLetter(r.Peek()):
+					r.NextWhile(unicode.IsLetter)
+					return IDENT
+				}
+				return ybase.EOF
+			},
+			want: []string{
+				`"" "a" " " 0-1`,
+				`"" ">" "" 2-3`,
+				`"" ">" " " 3-4`,
+				`"" "b" "\n" 5-6`,
+			},
+		},
+	} {
+		t.Run("emitted "+tc.title, func(t *testing.T) {
+			lexer := ybase.NewLexer(ybase.NewScanner(newReader(tc.input), tc.scan), ybase.WithLossless())
+			tokens, err := ybase.Tokens(lexer)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, dump(tokens))
+			assert.Equal(t, tc.input, reconstruct(tokens, lexer.EOFTrivia()))
+		})
+	}
+}
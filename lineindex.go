@@ -0,0 +1,120 @@
+package ybase
+
+import (
+	"bytes"
+	"sort"
+)
+
+// LineIndex is an index of the lines of Bytes.
+//
+// LineIndex answers the same queries as Bytes in O(log n) after it is built in O(n).
+type LineIndex struct {
+	src Bytes
+	// starts are the offsets of the first bytes of the lines.
+	starts []int
+}
+
+// NewLineIndex builds the index of the lines of b.
+func NewLineIndex(b Bytes) *LineIndex {
+	starts := make([]int, 1, bytes.Count(b, []byte("\n"))+1)
+	for i, c := range b {
+		if c == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return &LineIndex{
+		src:    b,
+		starts: starts,
+	}
+}
+
+// LineCount returns the number of the lines.
+func (x *LineIndex) LineCount() int { return len(x.starts) }
+
+// LineStart returns the offset of the first byte of the line.
+func (x *LineIndex) LineStart(line int) (int, bool) {
+	if line < 1 || line > len(x.starts) {
+		return 0, false
+	}
+	return x.starts[line-1], true
+}
+
+// LineEnd returns the offset of the newline that terminates the line,
+// the length of the source if the line is the last.
+func (x *LineIndex) LineEnd(line int) (int, bool) {
+	if line < 1 || line > len(x.starts) {
+		return 0, false
+	}
+	if line == len(x.starts) {
+		return len(x.src), true
+	}
+	return x.starts[line] - 1, true
+}
+
+// line returns the line without the newline.
+func (x *LineIndex) line(line int) []byte {
+	start, _ := x.LineStart(line)
+	end, _ := x.LineEnd(line)
+	return x.src[start:end]
+}
+
+// LineColumn calculates line number and column from offset.
+func (x *LineIndex) LineColumn(offset int) (int, int, bool) {
+	if offset < 0 || offset >= len(x.src) {
+		return 0, 0, false
+	}
+	line := sort.SearchInts(x.starts, offset+1)
+	return line, offset - x.starts[line-1] + 1, true
+}
+
+// Offset calculates offset from line number and column.
+func (x *LineIndex) Offset(line, column int) (int, bool) {
+	if line < 1 || column < 1 || line > len(x.starts) {
+		return 0, false
+	}
+	if column-1 >= len(x.line(line)) {
+		return 0, false
+	}
+	return x.starts[line-1] + column - 1, true
+}
+
+// Context retrieves lines before and after a specified line number.
+func (x *LineIndex) Context(line, count int) (*Context, bool) {
+	if line < 1 || count < 0 || line > len(x.starts) {
+		return nil, false
+	}
+	lines := []*ContextLine{}
+	for linum := max(line-count, 1); linum <= min(line+count, len(x.starts)); linum++ {
+		lines = append(lines, &ContextLine{
+			Linum: linum,
+			Line:  x.line(linum),
+		})
+	}
+	return &Context{
+		Target: &ContextLine{
+			Linum: line,
+			Line:  x.line(line),
+		},
+		Lines: lines,
+	}, true
+}
+
+// Adjacency retrieves runes before and after a specified line and column.
+func (x *LineIndex) Adjacency(line, column, count int) (*Adjacency, bool) {
+	if line < 1 || column < 1 || count < 0 || line > len(x.starts) {
+		return nil, false
+	}
+	target := bytes.Runes(x.line(line))
+	if column-1 >= len(target) {
+		return nil, false
+	}
+	start := max(column-1-count, 0)
+	end := min(column+count, len(target))
+	return &Adjacency{
+		Linum:  line,
+		Column: column,
+		Focus:  target[column-1],
+		String: string(target[start:end]),
+		Line:   string(target),
+	}, true
+}
@@ -0,0 +1,94 @@
+package ybase_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/berquerant/ybase"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineIndex(t *testing.T) {
+	t.Run("lines", func(t *testing.T) {
+		x := ybase.NewLineIndex(ybase.Bytes("ab\n\ncd"))
+		assert.Equal(t, 3, x.LineCount())
+		for _, tc := range []struct {
+			line       int
+			start, end int
+			ok         bool
+		}{
+			{line: 0},
+			{line: 1, start: 0, end: 2, ok: true},
+			{line: 2, start: 3, end: 3, ok: true},
+			{line: 3, start: 4, end: 6, ok: true},
+			{line: 4},
+		} {
+			t.Run(fmt.Sprint(tc.line), func(t *testing.T) {
+				start, ok := x.LineStart(tc.line)
+				assert.Equal(t, tc.ok, ok)
+				assert.Equal(t, tc.start, start)
+				end, ok := x.LineEnd(tc.line)
+				assert.Equal(t, tc.ok, ok)
+				assert.Equal(t, tc.end, end)
+			})
+		}
+	})
+
+	for _, doc := range []string{
+		"",
+		"\n",
+		"a",
+		"\n\na\n",
+		"apiVersion: v1\nkind: Text\n\nspec:\n  text1: テキスト\n  text2: text\n",
+	} {
+		b := ybase.Bytes(doc)
+		x := ybase.NewLineIndex(b)
+		t.Run(fmt.Sprintf("same as Bytes %q", doc), func(t *testing.T) {
+			for offset := -1; offset <= len(doc)+1; offset++ {
+				line, col, ok := b.LineColumn(offset)
+				xline, xcol, xok := x.LineColumn(offset)
+				assert.Equal(t, []any{line, col, ok}, []any{xline, xcol, xok}, "LineColumn(%d)", offset)
+			}
+			for line := -1; line <= x.LineCount()+1; line++ {
+				for col := -1; col <= len(doc)+1; col++ {
+					offset, ok := b.Offset(line, col)
+					xoffset, xok := x.Offset(line, col)
+					assert.Equal(t, []any{offset, ok}, []any{xoffset, xok}, "Offset(%d, %d)", line, col)
+					for count := -1; count <= 2; count++ {
+						adj, ok := b.Adjacency(line, col, count)
+						xadj, xok := x.Adjacency(line, col, count)
+						assert.Equal(t, ok, xok, "Adjacency(%d, %d, %d)", line, col, count)
+						assert.Equal(t, adj, xadj, "Adjacency(%d, %d, %d)", line, col, count)
+					}
+				}
+				for count := -1; count <= 2; count++ {
+					ctx, ok := b.Context(line, count)
+					xctx, xok := x.Context(line, count)
+					assert.Equal(t, ok, xok, "Context(%d, %d)", line, count)
+					assert.Equal(t, ctx, xctx, "Context(%d, %d)", line, count)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkLineColumn(b *testing.B) {
+	var doc []byte
+	for i := range 10000 {
+		doc = fmt.Appendf(doc, "line %d\n", i)
+	}
+	src := ybase.Bytes(doc)
+
+	b.Run("Bytes", func(b *testing.B) {
+		for i := range b.N {
+			_, _, _ = src.LineColumn(i % len(src))
+		}
+	})
+	b.Run("LineIndex", func(b *testing.B) {
+		x := ybase.NewLineIndex(src)
+		b.ResetTimer()
+		for i := range b.N {
+			_, _, _ = x.LineColumn(i % len(src))
+		}
+	})
+}
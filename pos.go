@@ -1,20 +1,64 @@
 package ybase
 
 import (
+	"cmp"
 	"encoding/json"
 	"fmt"
+	"unicode/utf16"
 )
 
+// Newline is a convention of newlines.
+type Newline int
+
+const (
+	// NewlineLF treats \n as a newline.
+	NewlineLF Newline = iota
+	// NewlineCRLF treats \r\n as a newline, \r does not count as a column.
+	NewlineCRLF
+	// NewlineCR treats \r as a newline.
+	NewlineCR
+	// NewlineAny treats \n, \r\n, \r, U+0085, U+2028 and U+2029 as a newline.
+	NewlineAny
+)
+
+// ColumnUnit is a unit of columns.
+type ColumnUnit int
+
+const (
+	ColumnRune ColumnUnit = iota
+	ColumnByte
+	ColumnUTF16
+	// ColumnCell counts the cells in terminals, e.g. East Asian Wide runes are 2.
+	ColumnCell
+)
+
+// PosConfig configures the position tracking.
+type PosConfig struct {
+	Newline Newline
+	// TabWidth is the width of tab stops.
+	// Tab counts as a rune if 0 or less.
+	TabWidth int
+	Unit     ColumnUnit
+}
+
 type (
 	Pos interface {
 		Line() int
 		Column() int
 		Offset() int
 		Add(r rune) Pos
+		// Compare returns -1, 0 or +1 when the position is before, at or after other,
+		// ordered by the file and offset.
+		Compare(other Pos) int
+		// File returns the file of the position, nil if unknown.
+		File() *File
 	}
 
 	pos struct {
 		line, col, offset int
+		cfg               *PosConfig
+		cr                bool // the last rune is \r
+		file              *File
 	}
 )
 
@@ -26,29 +70,124 @@ func NewPos(line, col, offset int) Pos {
 	}
 }
 
-func (s pos) Line() int      { return s.line }
-func (s pos) Column() int    { return s.col }
-func (s pos) Offset() int    { return s.offset }
+// copyPos returns a copy of the Pos.
+func copyPos(p Pos) *pos {
+	if x, ok := p.(*pos); ok {
+		y := *x
+		return &y
+	}
+	return &pos{
+		line:   p.Line(),
+		col:    p.Column(),
+		offset: p.Offset(),
+		file:   p.File(),
+	}
+}
+
+// NewPosWithConfig returns a new Pos that tracks the position by the config.
+func NewPosWithConfig(line, col, offset int, cfg PosConfig) Pos {
+	return &pos{
+		line:   line,
+		col:    col,
+		offset: offset,
+		cfg:    &cfg,
+	}
+}
+
+func (s pos) Line() int   { return s.line }
+func (s pos) Column() int { return s.col }
+func (s pos) Offset() int { return s.offset }
+func (s pos) File() *File { return s.file }
+func (s pos) Compare(other Pos) int {
+	return cmp.Or(
+		cmp.Compare(baseOf(s.file), baseOf(other.File())),
+		cmp.Compare(s.offset, other.Offset()),
+	)
+}
 func (s pos) String() string { return fmt.Sprintf("%d,%d,%d", s.line, s.col, s.offset) }
 func (s *pos) Add(r rune) Pos {
 	size := len([]byte(string(r)))
-	if r == '\n' {
-		return &pos{
-			line:   s.line + 1,
-			col:    0,
-			offset: s.offset + size,
-		}
+	var cfg PosConfig
+	if s.cfg != nil {
+		cfg = *s.cfg
 	}
-	return &pos{
+	next := &pos{
 		line:   s.line,
-		col:    s.col + 1,
+		col:    s.col,
 		offset: s.offset + size,
+		cfg:    s.cfg,
+		file:   s.file,
+	}
+
+	switch cfg.Newline {
+	case NewlineCRLF:
+		if r == '\r' {
+			return next
+		}
+	case NewlineCR:
+		if r == '\r' {
+			next.line++
+			next.col = 0
+			return next
+		}
+		if r == '\n' {
+			next.col += cfg.width(r, s.col)
+			return next
+		}
+	case NewlineAny:
+		switch r {
+		case '\n':
+			if s.cr {
+				return next
+			}
+		case '\r':
+			next.cr = true
+			next.line++
+			next.col = 0
+			return next
+		case '\u0085', '\u2028', '\u2029':
+			next.line++
+			next.col = 0
+			return next
+		}
+	}
+
+	if r == '\n' {
+		next.line++
+		next.col = 0
+		return next
+	}
+	next.col += cfg.width(r, s.col)
+	return next
+}
+
+// width returns the columns of the rune at the column.
+func (c PosConfig) width(r rune, col int) int {
+	if r == '\t' && c.TabWidth > 0 {
+		return c.TabWidth - col%c.TabWidth
+	}
+	switch c.Unit {
+	case ColumnByte:
+		return len(string(r))
+	case ColumnUTF16:
+		if n := utf16.RuneLen(r); n > 0 {
+			return n
+		}
+		return 1
+	case ColumnCell:
+		return runeWidth(r)
+	default:
+		return 1
 	}
 }
 func (s pos) MarshalJSON() ([]byte, error) {
-	return json.Marshal(map[string]any{
+	v := map[string]any{
 		"line":   s.line,
 		"col":    s.col,
 		"offset": s.offset,
-	})
+	}
+	if s.file != nil {
+		v["file"] = s.file.Name()
+	}
+	return json.Marshal(v)
 }
@@ -53,3 +53,83 @@ func TestPos(t *testing.T) {
 		})
 	}
 }
+
+func TestPosWithConfig(t *testing.T) {
+	type want struct {
+		line, col, offset int
+	}
+	for _, tc := range []struct {
+		title string
+		cfg   ybase.PosConfig
+		input string
+		want  want
+	}{
+		{
+			title: "lf",
+			input: "a\r\nb",
+			want:  want{line: 2, col: 1, offset: 4},
+		},
+		{
+			title: "crlf",
+			cfg:   ybase.PosConfig{Newline: ybase.NewlineCRLF},
+			input: "a\r\nb\r",
+			want:  want{line: 2, col: 1, offset: 5},
+		},
+		{
+			title: "cr",
+			cfg:   ybase.PosConfig{Newline: ybase.NewlineCR},
+			input: "a\rb\n",
+			want:  want{line: 2, col: 2, offset: 4},
+		},
+		{
+			title: "any crlf",
+			cfg:   ybase.PosConfig{Newline: ybase.NewlineAny},
+			input: "a\r\nb\rc\n\u2028d",
+			want:  want{line: 5, col: 1, offset: 11},
+		},
+		{
+			title: "any lf lf",
+			cfg:   ybase.PosConfig{Newline: ybase.NewlineAny},
+			input: "\r\r\n\n",
+			want:  want{line: 4, col: 0, offset: 4},
+		},
+		{
+			title: "tab",
+			cfg:   ybase.PosConfig{TabWidth: 4},
+			input: "\tab\tc\t",
+			want:  want{line: 1, col: 12, offset: 6},
+		},
+		{
+			title: "byte",
+			cfg:   ybase.PosConfig{Unit: ybase.ColumnByte},
+			input: "aあ",
+			want:  want{line: 1, col: 4, offset: 4},
+		},
+		{
+			title: "utf16",
+			cfg:   ybase.PosConfig{Unit: ybase.ColumnUTF16},
+			input: "aあ😀",
+			want:  want{line: 1, col: 4, offset: 8},
+		},
+		{
+			title: "cell",
+			cfg:   ybase.PosConfig{Unit: ybase.ColumnCell},
+			input: "aあ́",
+			want:  want{line: 1, col: 3, offset: 6},
+		},
+		{
+			title: "cell tab",
+			cfg:   ybase.PosConfig{Unit: ybase.ColumnCell, TabWidth: 8},
+			input: "あ\t",
+			want:  want{line: 1, col: 8, offset: 4},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			p := ybase.NewPosWithConfig(1, 0, 0, tc.cfg)
+			for _, r := range tc.input {
+				p = p.Add(r)
+			}
+			assert.Equal(t, tc.want, want{line: p.Line(), col: p.Column(), offset: p.Offset()})
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package ybase
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+)
+
+// Category is a category of token types for tooling.
+type Category int
+
+const (
+	CategoryUnknown Category = iota
+	CategoryKeyword
+	CategoryOperator
+	CategoryLiteral
+	CategoryIdentifier
+	CategoryComment
+	CategoryPunctuation
+)
+
+func (c Category) String() string {
+	switch c {
+	case CategoryUnknown:
+		return "unknown"
+	case CategoryKeyword:
+		return "keyword"
+	case CategoryOperator:
+		return "operator"
+	case CategoryLiteral:
+		return "literal"
+	case CategoryIdentifier:
+		return "identifier"
+	case CategoryComment:
+		return "comment"
+	case CategoryPunctuation:
+		return "punctuation"
+	default:
+		return fmt.Sprintf("Category(%d)", int(c))
+	}
+}
+
+// parseCategory is the inverse of Category.String.
+func parseCategory(s string) Category {
+	for c := CategoryUnknown; c <= CategoryPunctuation; c++ {
+		if c.String() == s {
+			return c
+		}
+	}
+	return CategoryUnknown
+}
+
+// TokenKind is the metadata of a token type.
+type TokenKind struct {
+	Type     int
+	Name     string
+	Category Category
+}
+
+// Registry is a set of TokenKind.
+//
+// A nil *Registry is empty.
+// Registry is not safe for concurrent registration.
+type Registry struct {
+	kinds map[int]TokenKind
+	types map[string]int
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		kinds: map[int]TokenKind{},
+		types: map[string]int{},
+	}
+}
+
+// Register registers the token type.
+func (r *Registry) Register(t int, name string, category Category) *Registry {
+	if old, ok := r.kinds[t]; ok {
+		delete(r.types, old.Name)
+	}
+	r.kinds[t] = TokenKind{
+		Type:     t,
+		Name:     name,
+		Category: category,
+	}
+	r.types[name] = t
+	return r
+}
+
+// FirstYaccToken is the value of the first token declared by %token in goyacc.
+const FirstYaccToken = 57346
+
+// RegisterYacc registers the token types generated by goyacc, yyToknames and yyToknum.
+//
+// If toknum is nil, the types are numbered as goyacc does:
+// $end is EOF, error is ErrorToken, character literals like '+' are the characters
+// and the others are numbered from FirstYaccToken in order.
+func (r *Registry) RegisterYacc(toknames []string, toknum []int) *Registry {
+	next := FirstYaccToken
+	for i, name := range toknames {
+		var t int
+		switch {
+		case toknum != nil:
+			if i >= len(toknum) {
+				return r
+			}
+			t = toknum[i]
+		case name == "$end":
+			t = EOF
+		case name == "error":
+			t = ErrorToken
+		case name == "$unk":
+			t = ErrorToken + 1
+		default:
+			if x, err := strconv.Unquote(name); err == nil && len(name) > 2 && name[0] == '\'' {
+				t = int([]rune(x)[0])
+				break
+			}
+			t = next
+			next++
+		}
+		if r.Contains(t) {
+			continue
+		}
+		r.Register(t, name, CategoryUnknown)
+	}
+	return r
+}
+
+// Contains reports whether the token type is registered.
+func (r *Registry) Contains(t int) bool {
+	if r == nil {
+		return false
+	}
+	_, ok := r.kinds[t]
+	return ok
+}
+
+// Kind returns the kind of the token type.
+// Returns a kind named by the number if not registered.
+func (r *Registry) Kind(t int) TokenKind {
+	if r != nil {
+		if k, ok := r.kinds[t]; ok {
+			return k
+		}
+	}
+	return TokenKind{
+		Type: t,
+		Name: strconv.Itoa(t),
+	}
+}
+
+// Name returns the name of the token type.
+func (r *Registry) Name(t int) string { return r.Kind(t).Name }
+
+// Type returns the token type of the name.
+func (r *Registry) Type(name string) (int, bool) {
+	if r == nil {
+		return 0, false
+	}
+	t, ok := r.types[name]
+	return t, ok
+}
+
+// Kinds returns the registered kinds ordered by the types.
+func (r *Registry) Kinds() []TokenKind {
+	if r == nil {
+		return nil
+	}
+	kinds := make([]TokenKind, 0, len(r.kinds))
+	for _, t := range slices.Sorted(maps.Keys(r.kinds)) {
+		kinds = append(kinds, r.kinds[t])
+	}
+	return kinds
+}
@@ -0,0 +1,80 @@
+package ybase_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"unicode"
+
+	"github.com/berquerant/ybase"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Run("register", func(t *testing.T) {
+		reg := ybase.NewRegistry().
+			Register(901, "NUM", ybase.CategoryLiteral).
+			Register(911, "PLUS", ybase.CategoryOperator)
+		assert.Equal(t, ybase.TokenKind{Type: 901, Name: "NUM", Category: ybase.CategoryLiteral}, reg.Kind(901))
+		assert.Equal(t, "PLUS", reg.Name(911))
+		assert.Equal(t, "999", reg.Name(999))
+		assert.False(t, reg.Contains(999))
+		tp, ok := reg.Type("PLUS")
+		assert.True(t, ok)
+		assert.Equal(t, 911, tp)
+		assert.Equal(t, 2, len(reg.Kinds()))
+		assert.Equal(t, "operator", reg.Kind(911).Category.String())
+
+		reg.Register(911, "ADD", ybase.CategoryOperator)
+		_, ok = reg.Type("PLUS")
+		assert.False(t, ok)
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		var reg *ybase.Registry
+		assert.Equal(t, "1", reg.Name(1))
+		assert.Nil(t, reg.Kinds())
+	})
+
+	t.Run("yacc", func(t *testing.T) {
+		toknames := []string{"$end", "error", "$unk", "NUM", "'+'", "IDENT"}
+		reg := ybase.NewRegistry().RegisterYacc(toknames, nil)
+		assert.Equal(t, "$end", reg.Name(ybase.EOF))
+		assert.Equal(t, "error", reg.Name(ybase.ErrorToken))
+		assert.Equal(t, "NUM", reg.Name(57346))
+		assert.Equal(t, "'+'", reg.Name('+'))
+		assert.Equal(t, "IDENT", reg.Name(57347))
+
+		reg = ybase.NewRegistry().RegisterYacc([]string{"A", "B"}, []int{300, 301})
+		assert.Equal(t, "B", reg.Name(301))
+	})
+}
+
+func TestTokenKind(t *testing.T) {
+	reg := ybase.NewRegistry().Register(1, "IDENT", ybase.CategoryIdentifier)
+	s := ybase.NewLexer(ybase.NewScanner(newReader("ab 12"), ybase.NewRuleScanFunc(
+		ybase.Rule{Pattern: ybase.Runes(unicode.IsSpace), Skip: true},
+		ybase.Rule{Type: 1, Pattern: ybase.Runes(unicode.IsLetter)},
+		ybase.Rule{Type: 10, Pattern: ybase.Runes(unicode.IsDigit)},
+	)), ybase.WithRegistry(reg))
+	toks, err := ybase.Tokens(s)
+	assert.Nil(t, err)
+	if !assert.Equal(t, 2, len(toks)) {
+		return
+	}
+
+	assert.Equal(t, "IDENT,ab", fmt.Sprint(toks[0]))
+	assert.Equal(t, ybase.CategoryIdentifier, toks[0].Kind().Category)
+	assert.Equal(t, "10,12", fmt.Sprint(toks[1]))
+
+	b, err := json.Marshal(toks[0])
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{
+  "type": 1,
+  "name": "IDENT",
+  "category": "identifier",
+  "value": "ab",
+  "start": {"line": 1, "col": 0, "offset": 0},
+  "end": {"line": 1, "col": 2, "offset": 2}
+}`, string(b))
+}
@@ -0,0 +1,179 @@
+package ybase
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"unicode/utf8"
+)
+
+var ErrNoRuleMatched = errors.New("NoRuleMatched")
+
+// Pattern matches the head of the input.
+type Pattern interface {
+	// Match returns the number of the matched runes without consuming them.
+	// Returns 0 if not matched.
+	Match(r Reader) int
+}
+
+type literalPattern string
+
+// Literal matches s.
+func Literal(s string) Pattern { return literalPattern(s) }
+
+func (p literalPattern) Match(r Reader) int {
+	if p == "" || !r.HasPrefix(string(p)) {
+		return 0
+	}
+	return utf8.RuneCountInString(string(p))
+}
+
+type runesPattern func(rune) bool
+
+// Runes matches one or more runes satisfying pred.
+func Runes(pred func(rune) bool) Pattern { return runesPattern(pred) }
+
+func (p runesPattern) Match(r Reader) int {
+	a := newLookahead(r)
+	var n int
+	for {
+		x, ok := a.at(n)
+		if !ok || !p(x) {
+			return n
+		}
+		n++
+	}
+}
+
+type regexpPattern struct {
+	re *regexp.Regexp
+}
+
+// Regexp matches re at the head of the input.
+// The leftmost-longest match is used.
+func Regexp(re *regexp.Regexp) Pattern {
+	x := regexp.MustCompile(`^(?:` + re.String() + `)`)
+	x.Longest()
+	return &regexpPattern{
+		re: x,
+	}
+}
+
+func (p *regexpPattern) Match(r Reader) int {
+	a := newLookahead(r)
+	loc := p.re.FindReaderIndex(&a)
+	if loc == nil || loc[1] == 0 {
+		return 0
+	}
+	var n, size int
+	for size < loc[1] {
+		x, _ := a.at(n)
+		size += utf8.RuneLen(x)
+		n++
+	}
+	return n
+}
+
+// lookahead reads the input through Reader.PeekN.
+type lookahead struct {
+	r     Reader
+	rd    *reader // fast path for the builtin reader
+	runes []rune
+	eof   bool
+	i     int
+}
+
+func newLookahead(r Reader) lookahead {
+	a := lookahead{
+		r: r,
+	}
+	if x, ok := r.(*reader); ok {
+		a.rd = x
+	}
+	return a
+}
+
+// at returns the i-th rune from the head of the input.
+func (a *lookahead) at(i int) (rune, bool) {
+	if a.rd != nil {
+		return a.rd.peekAt(i)
+	}
+	for i >= len(a.runes) && !a.eof {
+		n := max(2*len(a.runes), 16)
+		a.runes = a.r.PeekN(n)
+		a.eof = len(a.runes) < n
+	}
+	if i < len(a.runes) {
+		return a.runes[i], true
+	}
+	return 0, false
+}
+
+// ReadRune implements io.RuneReader.
+func (a *lookahead) ReadRune() (rune, int, error) {
+	x, ok := a.at(a.i)
+	if !ok {
+		return 0, 0, io.EOF
+	}
+	a.i++
+	return x, utf8.RuneLen(x), nil
+}
+
+// Rule is a pair of a pattern and a token type.
+type Rule struct {
+	Type    int
+	Pattern Pattern
+	// Skip discards the matched runes instead of returning a token, e.g. whitespaces and comments.
+	Skip bool
+}
+
+// NewRuleScanFunc returns a ScanFunc that scans the input by the rules.
+//
+// The longest match wins, and the first rule wins among the matches of the same length.
+// Sets ErrNoRuleMatched if no rule matches.
+func NewRuleScanFunc(rules ...Rule) ScanFunc {
+	return func(r Reader) int {
+		return scanMatch(r, func(r Reader) (int, int) {
+			index, size := -1, 0
+			for i, rule := range rules {
+				if n := rule.Pattern.Match(r); n > size {
+					index, size = i, n
+				}
+			}
+			return index, size
+		}, func(index int) (int, bool) {
+			return rules[index].Type, rules[index].Skip
+		})
+	}
+}
+
+// scanMatch consumes the input until a token is found.
+//
+// match returns the index of the matched rule and the number of the matched runes, -1 if not matched.
+// rule returns the token type and the skip flag of the rule.
+func scanMatch(r Reader, match func(Reader) (int, int), rule func(int) (int, bool)) int {
+	for {
+		if r.Peek() == EOF {
+			return EOF
+		}
+
+		index, size := match(r)
+		if index < 0 {
+			r.Errorf(ErrNoRuleMatched, fmt.Sprintf("unexpected %q", r.Peek()))
+			return EOF
+		}
+
+		t, skip := rule(index)
+		for range size {
+			if skip {
+				_ = r.Discard()
+			} else {
+				_ = r.Next()
+			}
+		}
+		if !skip {
+			return t
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package ybase_test
+
+import (
+	"bytes"
+	"log/slog"
+	"regexp"
+	"testing"
+	"unicode"
+
+	"github.com/berquerant/ybase"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuleScanFunc(t *testing.T) {
+	const (
+		tIf = iota + 1
+		tIdent
+		tNum
+		tLt
+		tShl
+		tShlAssign
+	)
+	scan := ybase.NewRuleScanFunc(
+		ybase.Rule{Pattern: ybase.Runes(unicode.IsSpace), Skip: true},
+		ybase.Rule{Pattern: ybase.Regexp(regexp.MustCompile(`//[^\n]*`)), Skip: true},
+		ybase.Rule{Type: tIf, Pattern: ybase.Literal("if")},
+		ybase.Rule{Type: tIdent, Pattern: ybase.Regexp(regexp.MustCompile(`[\p{L}_][\p{L}\d_]*`))},
+		ybase.Rule{Type: tNum, Pattern: ybase.Runes(unicode.IsDigit)},
+		ybase.Rule{Type: tLt, Pattern: ybase.Literal("<")},
+		ybase.Rule{Type: tShl, Pattern: ybase.Literal("<<")},
+		ybase.Rule{Type: tShlAssign, Pattern: ybase.Literal("<<=")},
+	)
+
+	for _, tc := range []struct {
+		title string
+		input string
+		want  []ybase.Token
+		err   error
+	}{
+		{
+			title: "empty",
+			input: "",
+			want:  []ybase.Token{},
+		},
+		{
+			title: "maximal munch",
+			input: "a<<=1<<2<3",
+			want: newTokens(
+				tIdent, "a",
+				tShlAssign, "<<=",
+				tNum, "1",
+				tShl, "<<",
+				tNum, "2",
+				tLt, "<",
+				tNum, "3",
+			),
+		},
+		{
+			title: "first rule priority",
+			input: "if iff 変数 // comment\nif",
+			want: newTokens(
+				tIf, "if",
+				tIdent, "iff",
+				tIdent, "変数",
+				tIf, "if",
+			),
+		},
+		{
+			title: "no rule matched",
+			input: "x\n ?",
+			want: newTokens(
+				tIdent, "x",
+			),
+			err: ybase.ErrNoRuleMatched,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			s := ybase.NewLexer(ybase.NewScanner(ybase.NewReader(bytes.NewBufferString(tc.input), slog.Info), scan))
+			got := []ybase.Token{}
+			for s.DoLex(func(tok ybase.Token) { got = append(got, tok) }) != ybase.EOF {
+			}
+			if tc.err != nil {
+				assert.ErrorIs(t, s.Err(), tc.err)
+				assert.ErrorContains(t, s.Err(), `2:2: unexpected '?'`)
+			} else {
+				assert.Nil(t, s.Err())
+			}
+			assert.Equal(t, len(tc.want), len(got))
+			for i, w := range tc.want {
+				g := got[i]
+				assert.Equal(t, w.Type(), g.Type(), i)
+				assert.Equal(t, w.Value(), g.Value(), i)
+			}
+		})
+	}
+}
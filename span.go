@@ -0,0 +1,84 @@
+package ybase
+
+import "fmt"
+
+// Span is a range of the source from Start to End, End is exclusive.
+//
+// The zero Span is empty and has no positions.
+type Span struct {
+	Start Pos
+	End   Pos
+}
+
+func NewSpan(start, end Pos) Span {
+	return Span{
+		Start: start,
+		End:   end,
+	}
+}
+
+// IsZero reports whether the span has no positions.
+func (s Span) IsZero() bool { return s.Start == nil || s.End == nil }
+
+// Len returns the number of the bytes in the span.
+func (s Span) Len() int {
+	if s.IsZero() {
+		return 0
+	}
+	return max(s.End.Offset()-s.Start.Offset(), 0)
+}
+
+// Contains reports whether the position is in the span.
+// The position in another file is not in the span.
+func (s Span) Contains(p Pos) bool {
+	return !s.IsZero() && s.Start.Compare(p) <= 0 && p.Compare(s.End) < 0
+}
+
+// ContainsOffset reports whether the offset is in the span.
+func (s Span) ContainsOffset(offset int) bool {
+	return !s.IsZero() && s.Start.Offset() <= offset && offset < s.End.Offset()
+}
+
+// Overlaps reports whether the spans share a position.
+func (s Span) Overlaps(other Span) bool {
+	if s.IsZero() || other.IsZero() {
+		return false
+	}
+	return s.Start.Compare(other.End) < 0 && other.Start.Compare(s.End) < 0
+}
+
+// Union returns the smallest span that covers both spans.
+// The zero Span is the identity.
+func (s Span) Union(other Span) Span {
+	switch {
+	case s.IsZero():
+		return other
+	case other.IsZero():
+		return s
+	}
+	r := s
+	if other.Start.Compare(r.Start) < 0 {
+		r.Start = other.Start
+	}
+	if other.End.Compare(r.End) > 0 {
+		r.End = other.End
+	}
+	return r
+}
+
+// Text returns the text of the span in the source.
+func (s Span) Text(b Bytes) string {
+	if s.IsZero() {
+		return ""
+	}
+	start := min(max(s.Start.Offset(), 0), len(b))
+	end := min(max(s.End.Offset(), start), len(b))
+	return string(b[start:end])
+}
+
+func (s Span) String() string {
+	if s.IsZero() {
+		return "-"
+	}
+	return fmt.Sprintf("%v-%v", s.Start, s.End)
+}
@@ -0,0 +1,102 @@
+package ybase_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/berquerant/ybase"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPosCompare(t *testing.T) {
+	a := ybase.NewPos(1, 1, 1)
+	b := ybase.NewPos(2, 0, 3)
+	assert.Equal(t, -1, a.Compare(b))
+	assert.Equal(t, 1, b.Compare(a))
+	assert.Equal(t, 0, a.Compare(ybase.NewPos(1, 1, 1)))
+}
+
+func TestSpan(t *testing.T) {
+	at := func(offset int) ybase.Pos { return ybase.NewPos(1, offset, offset) }
+	span := func(start, end int) ybase.Span { return ybase.NewSpan(at(start), at(end)) }
+
+	t.Run("Contains", func(t *testing.T) {
+		s := span(2, 4)
+		for _, tc := range []struct {
+			offset int
+			want   bool
+		}{
+			{offset: 1},
+			{offset: 2, want: true},
+			{offset: 3, want: true},
+			{offset: 4},
+		} {
+			assert.Equal(t, tc.want, s.Contains(at(tc.offset)), "%d", tc.offset)
+			assert.Equal(t, tc.want, s.ContainsOffset(tc.offset), "%d", tc.offset)
+		}
+		assert.False(t, ybase.Span{}.ContainsOffset(0))
+		assert.False(t, ybase.Span{}.Contains(at(0)))
+
+		fset := ybase.NewFileSet()
+		a := fset.AddFile("a", ybase.Bytes("abcd"))
+		b := fset.AddFile("b", ybase.Bytes("abcd"))
+		s = ybase.NewSpan(a.StartPos().Add('a'), a.StartPos().Add('a').Add('b').Add('c'))
+		assert.True(t, s.Contains(a.StartPos().Add('a')))
+		assert.False(t, s.Contains(b.StartPos().Add('a')))
+		assert.False(t, s.Contains(at(1)))
+	})
+
+	t.Run("Overlaps", func(t *testing.T) {
+		for _, tc := range []struct {
+			title string
+			a, b  ybase.Span
+			want  bool
+		}{
+			{title: "disjoint", a: span(0, 2), b: span(3, 5)},
+			{title: "adjacent", a: span(0, 2), b: span(2, 5)},
+			{title: "overlap", a: span(0, 3), b: span(2, 5), want: true},
+			{title: "inner", a: span(0, 5), b: span(2, 3), want: true},
+			{title: "zero", a: span(0, 5), b: ybase.Span{}},
+		} {
+			t.Run(tc.title, func(t *testing.T) {
+				assert.Equal(t, tc.want, tc.a.Overlaps(tc.b))
+				assert.Equal(t, tc.want, tc.b.Overlaps(tc.a))
+			})
+		}
+	})
+
+	t.Run("Union", func(t *testing.T) {
+		assert.Equal(t, span(1, 6), span(1, 3).Union(span(4, 6)))
+		assert.Equal(t, span(1, 6), span(4, 6).Union(span(1, 3)))
+		assert.Equal(t, span(1, 6), span(1, 6).Union(span(2, 3)))
+		assert.Equal(t, span(1, 3), ybase.Span{}.Union(span(1, 3)))
+		assert.Equal(t, span(1, 3), span(1, 3).Union(ybase.Span{}))
+	})
+
+	t.Run("Text", func(t *testing.T) {
+		src := ybase.Bytes("let x = 1")
+		assert.Equal(t, "x", span(4, 5).Text(src))
+		assert.Equal(t, "1", span(8, 20).Text(src))
+		assert.Equal(t, "", ybase.Span{}.Text(src))
+		assert.Equal(t, 1, span(4, 5).Len())
+	})
+
+	t.Run("from tokens", func(t *testing.T) {
+		const input = "ab+cd"
+		tokens, err := ybase.Tokens(ybase.NewLexer(ybase.NewScanner(
+			ybase.NewReader(bytes.NewBufferString(input), nil),
+			ybase.NewRuleScanFunc(
+				ybase.Rule{Type: 1, Pattern: ybase.Runes(func(r rune) bool { return r != '+' })},
+				ybase.Rule{Type: '+', Pattern: ybase.Literal("+")},
+			),
+		)))
+		if !assert.Nil(t, err) || !assert.Len(t, tokens, 3) {
+			return
+		}
+		assert.Equal(t, "cd", tokens[2].Span().Text(ybase.Bytes(input)))
+		s := tokens[0].Span().Union(tokens[2].Span())
+		assert.Equal(t, input, s.Text(ybase.Bytes(input)))
+		assert.True(t, s.ContainsOffset(2))
+		assert.False(t, tokens[0].Span().Overlaps(tokens[1].Span()))
+	})
+}
@@ -11,6 +11,15 @@ type (
 		Value() string
 		Start() Pos
 		End() Pos
+		// Kind returns the kind of the type from the registry.
+		Kind() TokenKind
+		// Span returns the span from Start to End.
+		Span() Span
+		// LeadingTrivia returns the discarded text before the token in the lossless mode, see WithLossless.
+		LeadingTrivia() string
+		// TrailingTrivia returns the discarded text after the token
+		// up to and including the first newline in the lossless mode, see WithLossless.
+		TrailingTrivia() string
 	}
 
 	token struct {
@@ -18,9 +27,25 @@ type (
 		v     string
 		start Pos
 		end   Pos
+		reg   *Registry
+		// vstart is the position of the first rune of the value, nil if start.
+		vstart Pos
+
+		leading, trailing string
+		// states is the lexer state stack after the token, nil if the lexer may not restart after the token.
+		states []string
 	}
 )
 
+// valueStart returns the position of the first rune of the value.
+// The start of a token in the default mode is the end of the previous token, see WithLossless.
+func valueStart(t Token) Pos {
+	if x, ok := t.(*token); ok && x.vstart != nil {
+		return x.vstart
+	}
+	return t.Start()
+}
+
 func NewToken(t int, v string, start, end Pos) Token {
 	return &token{
 		t:     t,
@@ -30,16 +55,41 @@ func NewToken(t int, v string, start, end Pos) Token {
 	}
 }
 
-func (s token) Type() int      { return s.t }
-func (s token) Value() string  { return s.v }
-func (s token) Start() Pos     { return s.start }
-func (s token) End() Pos       { return s.end }
-func (s token) String() string { return fmt.Sprintf("%d,%s", s.t, s.v) }
+// NewTokenWithRegistry returns a new Token whose kind is from the registry.
+func NewTokenWithRegistry(t int, v string, start, end Pos, reg *Registry) Token {
+	return &token{
+		t:     t,
+		v:     v,
+		start: start,
+		end:   end,
+		reg:   reg,
+	}
+}
+
+func (s token) Type() int              { return s.t }
+func (s token) Value() string          { return s.v }
+func (s token) Start() Pos             { return s.start }
+func (s token) End() Pos               { return s.end }
+func (s token) Kind() TokenKind        { return s.reg.Kind(s.t) }
+func (s token) Span() Span             { return NewSpan(s.start, s.end) }
+func (s token) LeadingTrivia() string  { return s.leading }
+func (s token) TrailingTrivia() string { return s.trailing }
+func (s token) String() string         { return fmt.Sprintf("%s,%s", s.reg.Name(s.t), s.v) }
 func (s token) MarshalJSON() ([]byte, error) {
-	return json.Marshal(map[string]any{
-		"type":  s.t,
-		"value": s.v,
-		"start": s.start,
-		"end":   s.end,
-	})
+	kind := s.Kind()
+	v := map[string]any{
+		"type":     s.t,
+		"name":     kind.Name,
+		"category": kind.Category.String(),
+		"value":    s.v,
+		"start":    s.start,
+		"end":      s.end,
+	}
+	if s.leading != "" {
+		v["leading"] = s.leading
+	}
+	if s.trailing != "" {
+		v["trailing"] = s.trailing
+	}
+	return json.Marshal(v)
 }
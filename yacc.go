@@ -0,0 +1,73 @@
+package ybase
+
+// YaccLexer implements yyLexer of goyacc for the %union type S, e.g.
+//
+//	lexer := NewYaccLexer(ybase.NewLexer(scanner), func(lval *yySymType, tok ybase.Token) {
+//	  lval.token = tok  // declares in %union
+//	})
+//	yyParse(lexer)
+//	if err := lexer.Err(); err != nil { ... }
+type YaccLexer[S any] interface {
+	// Lex implements yyLexer.
+	Lex(lval *S) int
+	// Error implements yyLexer.
+	// Reports a *SyntaxError at the last token by Lexer.Error of the lexer.
+	Error(msg string)
+	// Err returns the error by the parser or the lexer, Lexer.Err of the lexer.
+	Err() error
+	// LastToken returns the last token passed to the parser, nil if none.
+	LastToken() Token
+}
+
+type yaccLexer[S any] struct {
+	lexer Lexer
+	set   func(*S, Token)
+	names func(string) string
+	last  Token
+}
+
+// YaccOption configures YaccLexer.
+type YaccOption func(*yaccConfig)
+
+type yaccConfig struct {
+	names func(string) string
+}
+
+// WithTokenNames maps the token names of goyacc in the messages to the names to display.
+// The names should not contain ", expecting " and " or " to parse the mapped messages.
+func WithTokenNames(names func(string) string) YaccOption {
+	return func(c *yaccConfig) {
+		c.names = names
+	}
+}
+
+// NewYaccLexer returns a YaccLexer that sets the tokens from the lexer by set.
+func NewYaccLexer[S any](lexer Lexer, set func(*S, Token), opts ...YaccOption) YaccLexer[S] {
+	var c yaccConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return &yaccLexer[S]{
+		lexer: lexer,
+		set:   set,
+		names: c.names,
+	}
+}
+
+func (l *yaccLexer[S]) LastToken() Token { return l.last }
+
+func (l *yaccLexer[S]) Lex(lval *S) int {
+	return l.lexer.DoLex(func(tok Token) {
+		l.last = tok
+		l.set(lval, tok)
+	})
+}
+
+func (l *yaccLexer[S]) Error(msg string) {
+	if l.names != nil {
+		msg = NewSyntaxError(msg, nil, nil, l.names).Message()
+	}
+	l.lexer.Error(msg)
+}
+
+func (l *yaccLexer[S]) Err() error { return l.lexer.Err() }
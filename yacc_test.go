@@ -0,0 +1,198 @@
+package ybase_test
+
+import (
+	"errors"
+	"testing"
+	"unicode"
+
+	"github.com/berquerant/ybase"
+	"github.com/stretchr/testify/assert"
+)
+
+type testSymType struct {
+	token ybase.Token
+}
+
+// testYYLexer is yyLexer generated by goyacc.
+type testYYLexer interface {
+	Lex(lval *testSymType) int
+	Error(s string)
+}
+
+func TestYaccLexer(t *testing.T) {
+	newLexer := func(input string) ybase.YaccLexer[testSymType] {
+		return ybase.NewYaccLexer(ybase.NewLexer(ybase.NewScanner(newReader(input), ybase.NewRuleScanFunc(
+			ybase.Rule{Pattern: ybase.Runes(unicode.IsSpace), Skip: true},
+			ybase.Rule{Type: 1, Pattern: ybase.Runes(unicode.IsLetter)},
+			ybase.Rule{Type: 10, Pattern: ybase.Runes(unicode.IsDigit)},
+		))), func(lval *testSymType, tok ybase.Token) {
+			lval.token = tok
+		})
+	}
+
+	t.Run("lex", func(t *testing.T) {
+		var (
+			lexer testYYLexer = newLexer("ab 12")
+			lval  testSymType
+		)
+		assert.Equal(t, 1, lexer.Lex(&lval))
+		assert.Equal(t, "ab", lval.token.Value())
+		assert.Equal(t, 10, lexer.Lex(&lval))
+		assert.Equal(t, "12", lval.token.Value())
+		assert.Equal(t, ybase.EOF, lexer.Lex(&lval))
+	})
+
+	t.Run("syntax error", func(t *testing.T) {
+		var (
+			lexer = newLexer("ab\ncd 12")
+			lval  testSymType
+		)
+		assert.Nil(t, lexer.LastToken())
+		_ = lexer.Lex(&lval)
+		_ = lexer.Lex(&lval)
+		assert.Equal(t, "cd", lexer.LastToken().Value())
+		lexer.Error("syntax error: unexpected IDENT, expecting NUM or '+' or $end")
+		lexer.Error("syntax error: unexpected NUM")
+
+		err := lexer.Err()
+		assert.ErrorIs(t, err, ybase.ErrYbase)
+		assert.ErrorIs(t, err, ybase.ErrSyntax)
+		var syntaxErr *ybase.SyntaxError
+		if !assert.True(t, errors.As(err, &syntaxErr)) {
+			return
+		}
+		assert.Equal(t, "IDENT", syntaxErr.Unexpected)
+		assert.Equal(t, []string{"NUM", "'+'", "$end"}, syntaxErr.Expected)
+		assert.Equal(t, "cd", syntaxErr.Token.Value())
+		assert.Equal(t, 3, syntaxErr.Start.Offset())
+		assert.Equal(t, 5, syntaxErr.End.Offset())
+		assert.Equal(t, "2:1: syntax error: unexpected IDENT, expecting NUM or '+' or $end", err.Error())
+	})
+
+	t.Run("token names", func(t *testing.T) {
+		var (
+			lexer = ybase.NewYaccLexer(ybase.NewLexer(ybase.NewScanner(newReader("ab"), ybase.NewRuleScanFunc(
+				ybase.Rule{Type: 1, Pattern: ybase.Runes(unicode.IsLetter)},
+			))), func(lval *testSymType, tok ybase.Token) {
+				lval.token = tok
+			}, ybase.WithTokenNames(func(s string) string {
+				switch s {
+				case "IDENT":
+					return "identifier"
+				case "$end":
+					return "end of file"
+				default:
+					return s
+				}
+			}))
+			lval testSymType
+		)
+		_ = lexer.Lex(&lval)
+		lexer.Error("syntax error: unexpected IDENT, expecting $end")
+		var syntaxErr *ybase.SyntaxError
+		if !assert.True(t, errors.As(lexer.Err(), &syntaxErr)) {
+			return
+		}
+		assert.Equal(t, "identifier", syntaxErr.Unexpected)
+		assert.Equal(t, []string{"end of file"}, syntaxErr.Expected)
+		assert.Equal(t, "syntax error: unexpected identifier, expecting end of file", syntaxErr.Message())
+
+		got := ybase.DiagnosticRenderer{Source: ybase.Bytes("ab")}.Render(syntaxErr.Diagnostic())
+		assert.Equal(t, `error: syntax error: unexpected identifier, expecting end of file
+ --> 1:1
+  |
+1 | ab
+  | ^~
+`, got)
+	})
+
+	t.Run("not verbose", func(t *testing.T) {
+		e := ybase.NewSyntaxError("syntax error", nil, ybase.NewPos(1, 0, 0), nil)
+		assert.Equal(t, "", e.Unexpected)
+		assert.Nil(t, e.Expected)
+		assert.Equal(t, "1:1: syntax error", e.Error())
+	})
+
+	t.Run("lexer", func(t *testing.T) {
+		lexer := ybase.NewLexer(ybase.NewScanner(newReader("ab 12"), ybase.NewRuleScanFunc(
+			ybase.Rule{Pattern: ybase.Runes(unicode.IsSpace), Skip: true},
+			ybase.Rule{Type: 1, Pattern: ybase.Runes(unicode.IsLetter)},
+			ybase.Rule{Type: 10, Pattern: ybase.Runes(unicode.IsDigit)},
+		)))
+		_ = lexer.DoLex(func(ybase.Token) {})
+		_ = lexer.DoLex(func(ybase.Token) {})
+		lexer.Error("syntax error: unexpected NUM")
+		var syntaxErr *ybase.SyntaxError
+		if !assert.True(t, errors.As(lexer.Err(), &syntaxErr)) {
+			return
+		}
+		assert.ErrorIs(t, lexer.Err(), ybase.ErrSyntax)
+		assert.Equal(t, "12", syntaxErr.Token.Value())
+		assert.Equal(t, "NUM", syntaxErr.Unexpected)
+		assert.Equal(t, 3, syntaxErr.Start.Offset())
+		assert.Equal(t, "1:4: syntax error: unexpected NUM", lexer.Err().Error())
+
+		var lexErr *ybase.LexError
+		if !assert.True(t, errors.As(lexer.Err(), &lexErr)) {
+			return
+		}
+		assert.Equal(t, 3, lexErr.Pos.Offset())
+		got := ybase.DiagnosticRenderer{Source: ybase.Bytes("ab 12")}.Render(lexErr.Diagnostic())
+		assert.Equal(t, `error: syntax error: unexpected NUM
+ --> 1:4
+  |
+1 | ab 12
+  |    ^~
+`, got)
+	})
+
+	t.Run("shared error", func(t *testing.T) {
+		lexer := ybase.NewLexer(ybase.NewScanner(newReader("ab cd"), ybase.NewRuleScanFunc(
+			ybase.Rule{Pattern: ybase.Runes(unicode.IsSpace), Skip: true},
+			ybase.Rule{Type: 1, Pattern: ybase.Runes(unicode.IsLetter)},
+		)))
+		var (
+			yaccLexer = ybase.NewYaccLexer(lexer, func(lval *testSymType, tok ybase.Token) {
+				lval.token = tok
+			})
+			lval testSymType
+		)
+		_ = yaccLexer.Lex(&lval)
+		yaccLexer.Error("syntax error: unexpected IDENT")
+		assert.Equal(t, lexer.Err(), yaccLexer.Err())
+		var lexErr *ybase.LexError
+		assert.True(t, errors.As(yaccLexer.Err(), &lexErr))
+		assert.Equal(t, ybase.EOF, yaccLexer.Lex(&lval))
+	})
+
+	t.Run("recovery", func(t *testing.T) {
+		lexer := ybase.NewLexer(ybase.NewScanner(newReader("ab cd"), ybase.NewRuleScanFunc(
+			ybase.Rule{Pattern: ybase.Runes(unicode.IsSpace), Skip: true},
+			ybase.Rule{Type: 1, Pattern: ybase.Runes(unicode.IsLetter)},
+		)), ybase.WithRecovery(nil))
+		var (
+			yaccLexer = ybase.NewYaccLexer(lexer, func(lval *testSymType, tok ybase.Token) {
+				lval.token = tok
+			})
+			lval testSymType
+		)
+		_ = yaccLexer.Lex(&lval)
+		yaccLexer.Error("syntax error: unexpected IDENT")
+		assert.Equal(t, 1, yaccLexer.Lex(&lval))
+		assert.Equal(t, "cd", lval.token.Value())
+		assert.Nil(t, yaccLexer.Err())
+		if assert.Equal(t, 1, len(lexer.Diagnostics())) {
+			assert.ErrorIs(t, lexer.Diagnostics()[0], ybase.ErrSyntax)
+		}
+	})
+
+	t.Run("lexer error", func(t *testing.T) {
+		var (
+			lexer = newLexer("ab ?")
+			lval  testSymType
+		)
+		for lexer.Lex(&lval) != ybase.EOF {
+		}
+		assert.ErrorIs(t, lexer.Err(), ybase.ErrNoRuleMatched)
+	})
+}
@@ -0,0 +1,434 @@
+package ybasetest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berquerant/ybase"
+)
+
+// ErrViolation is an error of Violation.
+var ErrViolation = errors.New("Violation")
+
+// ViolationKind is a kind of the invariants of lexers.
+type ViolationKind string
+
+const (
+	// ViolationProgress means Scan returned a token without consuming the input.
+	ViolationProgress ViolationKind = "progress"
+	// ViolationEmpty means a token has an empty value.
+	ViolationEmpty ViolationKind = "empty"
+	// ViolationSpan means End of a token is before Start
+	// or Start of a token is before End of the previous token.
+	ViolationSpan ViolationKind = "span"
+	// ViolationReconstruct means the tokens and the discarded text do not reconstruct the input.
+	ViolationReconstruct ViolationKind = "reconstruct"
+	// ViolationPanic means the lexer panicked.
+	ViolationPanic ViolationKind = "panic"
+	// ViolationTimeout means the lexer did not finish in time, e.g. an infinite loop in ScanFunc.
+	ViolationTimeout ViolationKind = "timeout"
+)
+
+// Violation is a broken invariant found by Check.
+//
+// errors.Is(err, ErrViolation) reports true for Violation.
+type Violation struct {
+	Kind  ViolationKind
+	Input string
+	Msg   string
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("%s: input %q: %s", v.Kind, v.Input, v.Msg)
+}
+
+func (v *Violation) Is(target error) bool { return target == ErrViolation }
+
+// WithTimeout sets the time limit of Check for an input, default is 1 second.
+func WithTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.timeout = d
+	}
+}
+
+// Check lexes the input with scan and reports the first broken invariant as *Violation, nil if none.
+//
+// The invariants are:
+//   - every Scan that returns a token consumes the input
+//   - no tokens have empty values
+//   - Start <= End for every token, and Start is not before End of the previous token
+//   - the tokens and the discarded text reconstruct the input in order,
+//     or a prefix of the input if the lexer stopped by an error
+//   - the lexer does not panic
+//   - the lexer finishes within the timeout
+//
+// The goroutine of the lexer is left running if it times out.
+func Check(input []byte, scan ybase.ScanFunc, opts ...Option) error {
+	return check(input, scan, newConfig(opts))
+}
+
+func check(input []byte, scan ybase.ScanFunc, cfg *config) error {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if x := recover(); x != nil {
+				done <- &Violation{
+					Kind:  ViolationPanic,
+					Input: string(input),
+					Msg:   fmt.Sprint(x),
+				}
+			}
+		}()
+		done <- newChecker(input, scan, cfg).run()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(cfg.timeout):
+		return &Violation{
+			Kind:  ViolationTimeout,
+			Input: string(input),
+			Msg:   fmt.Sprintf("not finished in %s", cfg.timeout),
+		}
+	}
+}
+
+// Shrink returns the smallest input found by removing runes from the input
+// that still breaks the same invariant, the input itself if Check reports nil or ViolationTimeout.
+func Shrink(input []byte, scan ybase.ScanFunc, opts ...Option) []byte {
+	return shrink(input, scan, newConfig(opts))
+}
+
+func shrink(input []byte, scan ybase.ScanFunc, cfg *config) []byte {
+	return shrinkKind(input, violationKind(check(input, scan, cfg)), scan, cfg)
+}
+
+// shrinkKind shrinks the input that breaks the invariant of the kind.
+// A timed out input is not shrunk because every timed out check leaves the goroutine running.
+func shrinkKind(input []byte, kind ViolationKind, scan ybase.ScanFunc, cfg *config) []byte {
+	if kind == "" || kind == ViolationTimeout {
+		return input
+	}
+	runes := bytes.Runes(input)
+	for n := len(runes) / 2; n > 0; n /= 2 {
+		for i := 0; i+n <= len(runes); {
+			candidate := append(append([]rune{}, runes[:i]...), runes[i+n:]...)
+			if violationKind(check([]byte(string(candidate)), scan, cfg)) == kind {
+				runes = candidate
+				continue
+			}
+			i++
+		}
+	}
+	return []byte(string(runes))
+}
+
+func violationKind(err error) ViolationKind {
+	var v *Violation
+	if errors.As(err, &v) {
+		return v.Kind
+	}
+	return ""
+}
+
+// CheckInputs runs Check over the inputs and reports the broken invariants with the minimal inputs.
+func CheckInputs(t testing.TB, scan ybase.ScanFunc, inputs []string, opts ...Option) {
+	t.Helper()
+	cfg := newConfig(opts)
+	for _, input := range inputs {
+		reportViolation(t, []byte(input), scan, cfg)
+	}
+}
+
+// Fuzz runs Check over the fuzzing inputs and reports the broken invariants with the minimal inputs.
+// Add the seed corpus to f before Fuzz.
+func Fuzz(f *testing.F, scan ybase.ScanFunc, opts ...Option) {
+	f.Helper()
+	cfg := newConfig(opts)
+	f.Fuzz(func(t *testing.T, input []byte) {
+		reportViolation(t, input, scan, cfg)
+	})
+}
+
+func reportViolation(t testing.TB, input []byte, scan ybase.ScanFunc, cfg *config) {
+	t.Helper()
+	err := check(input, scan, cfg)
+	if err == nil {
+		return
+	}
+	var v *Violation
+	if !errors.As(err, &v) {
+		t.Errorf("ybasetest: %v", err)
+		return
+	}
+	if min := shrinkKind(input, v.Kind, scan, cfg); len(min) < len(input) {
+		err = check(min, scan, cfg)
+	}
+	t.Errorf("ybasetest: %v", err)
+}
+
+// checker lexes the input and checks the invariants.
+type checker struct {
+	input    []byte
+	scan     ybase.ScanFunc
+	cfg      *config
+	reader   *recorder
+	violated *Violation
+}
+
+func newChecker(input []byte, scan ybase.ScanFunc, cfg *config) *checker {
+	return &checker{
+		input: input,
+		scan:  scan,
+		cfg:   cfg,
+	}
+}
+
+func (c *checker) violate(kind ViolationKind, format string, v ...any) {
+	if c.violated != nil {
+		return
+	}
+	c.violated = &Violation{
+		Kind:  kind,
+		Input: string(c.input),
+		Msg:   fmt.Sprintf(format, v...),
+	}
+}
+
+func (c *checker) scanFunc(r ybase.Reader) int {
+	offset := r.Pos().Offset()
+	t := c.scan(r)
+	if t != ybase.EOF && r.Pos().Offset() == offset && r.Err() == nil {
+		c.violate(ViolationProgress, "Scan returned %d at offset %d without consuming the input", t, offset)
+	}
+	return t
+}
+
+func (c *checker) run() error {
+	c.reader = newRecorder(ybase.NewReader(bytes.NewReader(c.input), nil, c.cfg.readerOpts...))
+	lexer := ybase.NewLexer(ybase.NewScanner(c.reader, c.scanFunc), c.cfg.lexerOpts...)
+
+	var prev ybase.Token
+	for c.violated == nil {
+		var (
+			tok     ybase.Token
+			emitted bool
+		)
+		if lexer.DoLex(func(t ybase.Token) {
+			tok = t
+			emitted = c.reader.emit(t, c.violate)
+		}) == ybase.EOF {
+			break
+		}
+		switch {
+		case tok.Value() == "" && !emitted:
+			c.violate(ViolationEmpty, "token %d at %s has an empty value", tok.Type(), ybase.FormatPos(tok.Start()))
+		case tok.Start().Compare(tok.End()) > 0:
+			c.violate(ViolationSpan, "token %q ends at %s before the start %s",
+				tok.Value(), ybase.FormatPos(tok.End()), ybase.FormatPos(tok.Start()))
+		case prev != nil && tok.Start().Compare(prev.End()) < 0:
+			c.violate(ViolationSpan, "token %q starts at %s before the end of the previous token %s",
+				tok.Value(), ybase.FormatPos(tok.Start()), ybase.FormatPos(prev.End()))
+		}
+		prev = tok
+	}
+	if c.violated != nil {
+		return c.violated
+	}
+
+	var (
+		want = string(bytes.Runes(c.input)) // invalid bytes are read as utf8.RuneError
+		got  = c.reader.text()
+	)
+	if lexer.Err() != nil {
+		// the pending runes are reported by the error
+		got += string(c.reader.pending.runes())
+		if !strings.HasPrefix(want, got) {
+			c.violate(ViolationReconstruct, "got %q before the error %v, not a prefix of the input", got, lexer.Err())
+		}
+		return c.violatedErr()
+	}
+	switch {
+	case len(c.reader.pending) > 0:
+		c.violate(ViolationReconstruct, "%q was consumed but not returned as a token", string(c.reader.pending.runes()))
+	case got != want:
+		c.violate(ViolationReconstruct, "got %q, lost %q", got, strings.TrimPrefix(want, got))
+	}
+	return c.violatedErr()
+}
+
+func (c *checker) violatedErr() error {
+	if c.violated == nil {
+		return nil
+	}
+	return c.violated
+}
+
+// consumed is a rune consumed from the input.
+type consumed struct {
+	offset int
+	r      rune
+}
+
+type consumedList []consumed
+
+func (xs consumedList) runes() []rune {
+	rs := make([]rune, len(xs))
+	for i, x := range xs {
+		rs[i] = x.r
+	}
+	return rs
+}
+
+// truncate removes the runes at or after the offset.
+func (xs consumedList) truncate(offset int) consumedList {
+	for i, x := range xs {
+		if x.offset >= offset {
+			return xs[:i]
+		}
+	}
+	return xs
+}
+
+// recorder records the consumed runes.
+//
+// The runes read by Next are pending until ResetBuffer,
+// which the lexer calls when it makes a token of the buffer, or the ScanFunc calls to discard them.
+type recorder struct {
+	ybase.Reader
+	// pending are the runes in the buffer.
+	pending consumedList
+	// accounted are the runes in the tokens or discarded.
+	accounted consumedList
+	// flushed is the text of the buffer at the last ResetBuffer.
+	flushed string
+	// emitted is the number of the tokens queued by Emit and not returned yet.
+	emitted int
+	// marks are the states at the marks by the ids.
+	marks map[int]recorderMark
+}
+
+type recorderMark struct {
+	pending, accounted consumedList
+	emitted            int
+}
+
+func newRecorder(r ybase.Reader) *recorder {
+	return &recorder{
+		Reader: r,
+		marks:  map[int]recorderMark{},
+	}
+}
+
+func (r *recorder) text() string { return string(r.accounted.runes()) }
+
+// emit checks the token returned by the lexer.
+// Returns true if the token is queued by Emit.
+func (r *recorder) emit(t ybase.Token, violate func(ViolationKind, string, ...any)) bool {
+	if r.emitted > 0 {
+		r.emitted--
+		return true
+	}
+	if t.Value() != r.flushed {
+		violate(ViolationReconstruct, "token value %q is not the consumed text %q", t.Value(), r.flushed)
+	}
+	return false
+}
+
+func (r *recorder) Emit(t int, value string, span ybase.Span) {
+	r.Reader.Emit(t, value, span)
+	r.emitted++
+}
+
+func (r *recorder) TakeEmitted() []ybase.Emission {
+	xs := r.Reader.TakeEmitted()
+	if r.Err() != nil {
+		// dropped by the error
+		r.emitted -= len(xs)
+	}
+	return xs
+}
+
+func (r *recorder) Next() rune {
+	offset := r.Pos().Offset()
+	x := r.Reader.Next()
+	if x != ybase.EOF {
+		r.pending = append(r.pending, consumed{offset: offset, r: x})
+	}
+	return x
+}
+
+func (r *recorder) NextWhile(pred func(rune) bool) {
+	for x := r.Peek(); pred(x); x = r.Peek() {
+		if r.Next() == ybase.EOF {
+			return
+		}
+	}
+}
+
+func (r *recorder) Discard() rune {
+	offset := r.Pos().Offset()
+	x := r.Reader.Discard()
+	if x != ybase.EOF {
+		r.accounted = append(r.accounted, consumed{offset: offset, r: x})
+	}
+	return x
+}
+
+func (r *recorder) DiscardWhile(pred func(rune) bool) {
+	for x := r.Peek(); pred(x); x = r.Peek() {
+		if r.Discard() == ybase.EOF {
+			return
+		}
+	}
+}
+
+func (r *recorder) ResetBuffer() {
+	// the runes of the token or discarded
+	r.flushed = string(r.pending.runes())
+	r.accounted = append(r.accounted, r.pending...)
+	r.pending = nil
+	r.Reader.ResetBuffer()
+}
+
+func (r *recorder) Mark() ybase.Mark {
+	m := r.Reader.Mark()
+	r.marks[m.ID()] = recorderMark{
+		pending:   append(consumedList{}, r.pending...),
+		accounted: append(consumedList{}, r.accounted...),
+		emitted:   r.emitted,
+	}
+	return m
+}
+
+func (r *recorder) Reset(m ybase.Mark) {
+	r.Reader.Reset(m)
+	if x, ok := r.marks[m.ID()]; ok {
+		r.pending = append(consumedList{}, x.pending...)
+		r.accounted = append(consumedList{}, x.accounted...)
+		r.emitted = x.emitted
+		return
+	}
+	offset := r.Pos().Offset()
+	r.pending = r.pending.truncate(offset)
+	r.accounted = r.accounted.truncate(offset)
+}
+
+func (r *recorder) Release(m ybase.Mark) {
+	r.Reader.Release(m)
+	delete(r.marks, m.ID())
+}
+
+func (r *recorder) Try(f func() bool) bool {
+	m := r.Mark()
+	defer r.Release(m)
+	if f() {
+		return true
+	}
+	r.Reset(m)
+	return false
+}
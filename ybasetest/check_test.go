@@ -0,0 +1,279 @@
+package ybasetest_test
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode"
+
+	"github.com/berquerant/ybase"
+	"github.com/berquerant/ybase/ybasetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	stop := make(chan struct{})
+	t.Cleanup(func() { close(stop) })
+
+	for _, tc := range []struct {
+		title string
+		scan  ybase.ScanFunc
+		input string
+		opts  []ybasetest.Option
+		want  ybasetest.ViolationKind
+	}{
+		{
+			title: "ok",
+			scan:  scan,
+			input: "ab 12\ncd",
+		},
+		{
+			title: "ok with an error",
+			scan:  scan,
+			input: "ab ?? 12",
+		},
+		{
+			title: "ok with recovery",
+			scan:  scan,
+			input: "ab ?? 12 ?",
+			opts: []ybasetest.Option{ybasetest.WithLexerOptions(
+				ybase.WithRecovery(unicode.IsSpace),
+			)},
+		},
+		{
+			title: "ok with invalid utf8",
+			scan:  scan,
+			input: "ab\xff",
+			opts: []ybasetest.Option{ybasetest.WithLexerOptions(
+				ybase.WithRecovery(nil),
+			)},
+		},
+		{
+			title: "ok with try",
+			scan: func(r ybase.Reader) int {
+				if r.Try(func() bool {
+					r.DiscardWhile(unicode.IsSpace)
+					return r.Next() == 'a'
+				}) {
+					return 1
+				}
+				r.DiscardWhile(unicode.IsSpace)
+				if r.Next() == ybase.EOF {
+					return ybase.EOF
+				}
+				return 2
+			},
+			input: "a b  a",
+		},
+		{
+			title: "ok with emit",
+			scan: func(r ybase.Reader) int {
+				switch {
+				case r.HasPrefix(">>"):
+					p := r.Pos()
+					_ = r.Next()
+					q := r.Pos()
+					_ = r.Next()
+					r.Emit('>', ">", ybase.NewSpan(p, q))
+					r.Emit('>', ">", ybase.NewSpan(q, r.Pos()))
+					return ybase.EOF
+				case r.Peek() == '\n':
+					r.Emit(';', "", ybase.Span{})
+					_ = r.Discard()
+					return ybase.EOF
+				}
+				if r.Next() == ybase.EOF {
+					return ybase.EOF
+				}
+				return 1
+			},
+			input: "a>>b\n>",
+		},
+		{
+			title: "no progress",
+			scan: func(r ybase.Reader) int {
+				if r.Peek() == ybase.EOF {
+					return ybase.EOF
+				}
+				return 1
+			},
+			input: "a",
+			want:  ybasetest.ViolationProgress,
+		},
+		{
+			title: "empty value",
+			scan: func(r ybase.Reader) int {
+				if r.Discard() == ybase.EOF {
+					return ybase.EOF
+				}
+				return 1
+			},
+			input: "a",
+			want:  ybasetest.ViolationEmpty,
+		},
+		{
+			title: "vanished input",
+			scan: func(r ybase.Reader) int {
+				r.DiscardWhile(unicode.IsSpace)
+				if !unicode.IsLetter(r.Peek()) {
+					return ybase.EOF
+				}
+				r.NextWhile(unicode.IsLetter)
+				return 1
+			},
+			input: "ab 12 cd",
+			want:  ybasetest.ViolationReconstruct,
+		},
+		{
+			title: "consumed but not returned",
+			scan: func(r ybase.Reader) int {
+				r.NextWhile(unicode.IsLetter)
+				return ybase.EOF
+			},
+			input: "ab",
+			want:  ybasetest.ViolationReconstruct,
+		},
+		{
+			title: "ok with reset buffer",
+			scan: func(r ybase.Reader) int {
+				// discard the first rune by the buffer
+				if r.Next() == ybase.EOF {
+					return ybase.EOF
+				}
+				r.ResetBuffer()
+				if r.Next() == ybase.EOF {
+					return ybase.EOF
+				}
+				return 1
+			},
+			input: "abc",
+		},
+		{
+			title: "empty after marks at the same offset",
+			scan: func(r ybase.Reader) int {
+				if r.Peek() == ybase.EOF {
+					return ybase.EOF
+				}
+				outer := r.Mark()
+				r.Emit('^', "", ybase.Span{})
+				inner := r.Mark()
+				r.Reset(inner)
+				r.Release(inner)
+				r.Reset(outer)
+				r.Release(outer)
+				_ = r.Discard()
+				return 1
+			},
+			input: "a",
+			want:  ybasetest.ViolationEmpty,
+		},
+		{
+			title: "panic",
+			scan: func(r ybase.Reader) int {
+				if r.Next() == 'x' {
+					panic("x")
+				}
+				return 1
+			},
+			input: "abx",
+			want:  ybasetest.ViolationPanic,
+		},
+		{
+			title: "timeout",
+			scan: func(r ybase.Reader) int {
+				for {
+					select {
+					case <-stop:
+						return ybase.EOF
+					default:
+						time.Sleep(time.Millisecond)
+					}
+				}
+			},
+			input: "a",
+			opts:  []ybasetest.Option{ybasetest.WithTimeout(20 * time.Millisecond)},
+			want:  ybasetest.ViolationTimeout,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			err := ybasetest.Check([]byte(tc.input), tc.scan, tc.opts...)
+			if tc.want == "" {
+				assert.Nil(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ybasetest.ErrViolation)
+			var v *ybasetest.Violation
+			if assert.ErrorAs(t, err, &v) {
+				assert.Equal(t, tc.want, v.Kind, v.Msg)
+				assert.Equal(t, tc.input, v.Input)
+			}
+		})
+	}
+}
+
+func TestShrink(t *testing.T) {
+	panicX := func(r ybase.Reader) int {
+		switch r.Next() {
+		case ybase.EOF:
+			return ybase.EOF
+		case 'x':
+			panic("x")
+		default:
+			return 1
+		}
+	}
+	assert.Equal(t, "x", string(ybasetest.Shrink([]byte("abc xyz x"), panicX)))
+	assert.Equal(t, "abc", string(ybasetest.Shrink([]byte("abc"), panicX)))
+
+	t.Run("timeout", func(t *testing.T) {
+		stop := make(chan struct{})
+		t.Cleanup(func() { close(stop) })
+		var calls atomic.Int32
+		spin := func(r ybase.Reader) int {
+			calls.Add(1)
+			<-stop
+			return ybase.EOF
+		}
+		opt := ybasetest.WithTimeout(10 * time.Millisecond)
+		assert.Equal(t, "abcd", string(ybasetest.Shrink([]byte("abcd"), spin, opt)))
+		assert.Equal(t, int32(1), calls.Load())
+
+		rt := &recordTB{TB: t}
+		ybasetest.CheckInputs(rt, spin, []string{"abcd"}, opt)
+		assert.Equal(t, 1, len(rt.errors))
+		assert.Equal(t, int32(2), calls.Load())
+	})
+}
+
+type recordTB struct {
+	testing.TB
+	errors []string
+}
+
+func (t *recordTB) Helper() {}
+func (t *recordTB) Errorf(format string, v ...any) {
+	t.errors = append(t.errors, fmt.Sprintf(format, v...))
+}
+
+func TestCheckInputs(t *testing.T) {
+	rt := &recordTB{TB: t}
+	vanish := func(r ybase.Reader) int {
+		if !unicode.IsLetter(r.Peek()) {
+			return ybase.EOF
+		}
+		r.NextWhile(unicode.IsLetter)
+		return 1
+	}
+	ybasetest.CheckInputs(rt, vanish, []string{"ab", "ab!cd"})
+	assert.Equal(t, []string{
+		`ybasetest: reconstruct: input "!": got "", lost "!"`,
+	}, rt.errors)
+}
+
+func FuzzCheck(f *testing.F) {
+	for _, x := range []string{"", "ab 12\ncd", "ab ?? 12 ?", "\xff"} {
+		f.Add([]byte(x))
+	}
+	ybasetest.Fuzz(f, scan, ybasetest.WithLexerOptions(ybase.WithRecovery(unicode.IsSpace)))
+}
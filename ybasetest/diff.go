@@ -0,0 +1,144 @@
+package ybasetest
+
+import (
+	"slices"
+	"strings"
+)
+
+// Diff returns the line diff from want to got, empty if they are equal.
+//
+// The removed lines start with "-", the added lines start with "+"
+// and the common lines start with " ".
+// The removed lines of a changed block come before the added lines.
+func Diff(want, got string) string {
+	if want == got {
+		return ""
+	}
+	var (
+		a = splitLines(want)
+		b = splitLines(got)
+		d strings.Builder
+	)
+	// the common prefix and suffix are not passed to the diff algorithm
+	p := 0
+	for p < len(a) && p < len(b) && a[p] == b[p] {
+		p++
+	}
+	s := 0
+	for s < len(a)-p && s < len(b)-p && a[len(a)-1-s] == b[len(b)-1-s] {
+		s++
+	}
+	for _, x := range a[:p] {
+		d.WriteString(" " + x + "\n")
+	}
+	var removed, added []string
+	flush := func() {
+		for _, x := range removed {
+			d.WriteString("-" + x + "\n")
+		}
+		for _, x := range added {
+			d.WriteString("+" + x + "\n")
+		}
+		removed, added = removed[:0], added[:0]
+	}
+	for _, op := range lineDiff(nil, a[p:len(a)-s], b[p:len(b)-s]) {
+		switch op.kind {
+		case '-':
+			removed = append(removed, op.line)
+		case '+':
+			added = append(added, op.line)
+		default:
+			flush()
+			d.WriteString(" " + op.line + "\n")
+		}
+	}
+	flush()
+	for _, x := range a[len(a)-s:] {
+		d.WriteString(" " + x + "\n")
+	}
+	if slices.Equal(a, b) {
+		d.WriteString("(the trailing newlines differ)\n")
+	}
+	return d.String()
+}
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// lineDiff appends the edit script from a to b to ops by Hirschberg's algorithm,
+// which finds a longest common subsequence in linear space.
+func lineDiff(ops []diffOp, a, b []string) []diffOp {
+	switch {
+	case len(a) == 0:
+		for _, x := range b {
+			ops = append(ops, diffOp{'+', x})
+		}
+		return ops
+	case len(b) == 0:
+		for _, x := range a {
+			ops = append(ops, diffOp{'-', x})
+		}
+		return ops
+	case len(a) == 1:
+		i := slices.Index(b, a[0])
+		if i < 0 {
+			ops = append(ops, diffOp{'-', a[0]})
+			return lineDiff(ops, nil, b)
+		}
+		ops = lineDiff(ops, nil, b[:i])
+		ops = append(ops, diffOp{' ', a[0]})
+		return lineDiff(ops, nil, b[i+1:])
+	}
+	// split b where the lcs of the upper half of a and the lcs of the lower half are the longest
+	m := len(a) / 2
+	var (
+		upper = lcsLengths(a[:m], b, false)
+		lower = lcsLengths(a[m:], b, true)
+		k     int
+	)
+	for j := range upper {
+		if upper[j]+lower[j] > upper[k]+lower[k] {
+			k = j
+		}
+	}
+	ops = lineDiff(ops, a[:m], b[:k])
+	return lineDiff(ops, a[m:], b[k:])
+}
+
+// lcsLengths returns the lengths of the longest common subsequences of a and b[:j] for each j,
+// of a and b[j:] if reverse.
+func lcsLengths(a, b []string, reverse bool) []int {
+	var (
+		prev = make([]int, len(b)+1)
+		cur  = make([]int, len(b)+1)
+	)
+	at := func(xs []string, i int) string {
+		if reverse {
+			return xs[len(xs)-1-i]
+		}
+		return xs[i]
+	}
+	for i := range a {
+		for j := range b {
+			if at(a, i) == at(b, j) {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	if reverse {
+		slices.Reverse(prev)
+	}
+	return prev
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,70 @@
+package ybasetest_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/berquerant/ybase/ybasetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	for _, tc := range []struct {
+		title     string
+		want, got string
+		diff      string
+	}{
+		{
+			title: "equal",
+			want:  "a\nb\n",
+			got:   "a\nb\n",
+		},
+		{
+			title: "changed",
+			want:  "a\nb\nc\n",
+			got:   "a\nx\nc\n",
+			diff:  " a\n-b\n+x\n c\n",
+		},
+		{
+			title: "added and removed",
+			want:  "a\nb\n",
+			got:   "b\nc\n",
+			diff:  "-a\n b\n+c\n",
+		},
+		{
+			title: "changed blocks",
+			want:  "a\nb\nc\nd\ne\nf\n",
+			got:   "x\nb\ny\nz\ne\nf\ng\n",
+			diff:  "-a\n+x\n b\n-c\n-d\n+y\n+z\n e\n f\n+g\n",
+		},
+		{
+			title: "moved",
+			want:  "a\nb\nc\nd\n",
+			got:   "c\nd\na\nb\n",
+			diff:  "-a\n-b\n c\n d\n+a\n+b\n",
+		},
+		{
+			title: "from empty",
+			want:  "",
+			got:   "a\n",
+			diff:  "+a\n",
+		},
+		{
+			title: "trailing newline",
+			want:  "a\n",
+			got:   "a",
+			diff:  " a\n(the trailing newlines differ)\n",
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			assert.Equal(t, tc.diff, ybasetest.Diff(tc.want, tc.got))
+		})
+	}
+
+	t.Run("long", func(t *testing.T) {
+		common := strings.Repeat("line\n", 100000)
+		diff := ybasetest.Diff(common+"a\n"+common, common+"b\n"+common)
+		assert.Equal(t, 200002, strings.Count(diff, "\n"))
+		assert.Contains(t, diff, " line\n-a\n+b\n line\n")
+	})
+}
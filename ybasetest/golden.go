@@ -0,0 +1,168 @@
+// Package ybasetest provides utilities to test lexers.
+package ybasetest
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berquerant/ybase"
+)
+
+var update = flag.Bool("ybasetest.update", false, "update the golden files of ybasetest")
+
+// The sections of the fixtures.
+const (
+	// InputSection is the input of the lexer.
+	// The input ends with a newline as the other sections of txtar.
+	InputSection = "input"
+	// TokensSection is the golden output, see Dump.
+	TokensSection = "tokens"
+)
+
+type config struct {
+	lexerOpts  []ybase.LexerOption
+	readerOpts []ybase.ReaderOption
+	update     bool
+	timeout    time.Duration
+}
+
+type Option func(*config)
+
+// WithLexerOptions passes the options to ybase.NewLexer.
+func WithLexerOptions(opts ...ybase.LexerOption) Option {
+	return func(c *config) {
+		c.lexerOpts = append(c.lexerOpts, opts...)
+	}
+}
+
+// WithReaderOptions passes the options to ybase.NewReader.
+func WithReaderOptions(opts ...ybase.ReaderOption) Option {
+	return func(c *config) {
+		c.readerOpts = append(c.readerOpts, opts...)
+	}
+}
+
+// WithUpdate rewrites the golden outputs if true.
+// Default is the -ybasetest.update flag.
+func WithUpdate(v bool) Option {
+	return func(c *config) {
+		c.update = v
+	}
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{
+		update:  *update,
+		timeout: time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Run lexes the input sections of the txtar fixtures matched by pattern with scan
+// and compares the outputs with the tokens sections, for each fixture as a subtest.
+//
+//	comment
+//	-- input --
+//	ab ?
+//	-- tokens --
+//	1:1-1:3 IDENT "ab"
+//	error: 1:4: unexpected '?': NoRuleMatched
+//
+// Run with -ybasetest.update to rewrite the tokens sections.
+func Run(t *testing.T, pattern string, scan ybase.ScanFunc, opts ...Option) {
+	t.Helper()
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		t.Fatalf("ybasetest: %v", err)
+	}
+	if len(paths) == 0 {
+		t.Fatalf("ybasetest: no fixtures match %s", pattern)
+	}
+	cfg := newConfig(opts)
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			runFixture(t, path, scan, cfg)
+		})
+	}
+}
+
+func runFixture(t *testing.T, path string, scan ybase.ScanFunc, cfg *config) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ybasetest: %v", err)
+	}
+	a := ParseArchive(data)
+	input, ok := a.File(InputSection)
+	if !ok {
+		t.Fatalf("ybasetest: %s: no %s section", path, InputSection)
+	}
+	got := dump(input, scan, cfg)
+
+	want, ok := a.File(TokensSection)
+	if cfg.update {
+		if ok && string(want) == got {
+			return
+		}
+		a.SetFile(TokensSection, []byte(got))
+		if err := os.WriteFile(path, a.Format(), 0o644); err != nil {
+			t.Fatalf("ybasetest: %v", err)
+		}
+		t.Logf("ybasetest: updated %s", path)
+		return
+	}
+	if !ok {
+		t.Fatalf("ybasetest: %s: no %s section, run with -ybasetest.update to create it", path, TokensSection)
+	}
+	if d := Diff(string(want), got); d != "" {
+		t.Errorf("ybasetest: %s: tokens differ (-want +got):\n%s", path, d)
+	}
+}
+
+// Dump lexes the input with scan and formats the tokens and the errors, one per line:
+//
+//	START-END NAME "VALUE"
+//	error: ERROR
+//
+// START and END are line:col, NAME is from the registry of the lexer.
+// The diagnostics of the recovery mode precede the tokens after them.
+func Dump(input []byte, scan ybase.ScanFunc, opts ...Option) string {
+	return dump(input, scan, newConfig(opts))
+}
+
+func dump(input []byte, scan ybase.ScanFunc, cfg *config) string {
+	var (
+		b     strings.Builder
+		lexer = ybase.NewLexer(
+			ybase.NewScanner(ybase.NewReader(bytes.NewReader(input), nil, cfg.readerOpts...), scan),
+			cfg.lexerOpts...,
+		)
+		ndiag int
+	)
+	diagnostics := func() {
+		xs := lexer.Diagnostics()
+		for _, x := range xs[ndiag:] {
+			fmt.Fprintf(&b, "error: %v\n", x)
+		}
+		ndiag = len(xs)
+	}
+	for tok, err := range lexer.All() {
+		diagnostics()
+		if err != nil {
+			fmt.Fprintf(&b, "error: %v\n", err)
+			break
+		}
+		fmt.Fprintf(&b, "%s-%s %s %q\n", ybase.FormatPos(tok.Start()), ybase.FormatPos(tok.End()), tok.Kind().Name, tok.Value())
+	}
+	diagnostics()
+	return b.String()
+}
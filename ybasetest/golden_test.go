@@ -0,0 +1,73 @@
+package ybasetest_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/berquerant/ybase"
+	"github.com/berquerant/ybase/ybasetest"
+	"github.com/stretchr/testify/assert"
+)
+
+// the packages importing ybasetest can define their own -update flag
+var _ = flag.Bool("update", false, "update the golden files")
+
+const (
+	IDENT = iota + 1
+	NUM
+)
+
+var (
+	registry = ybase.NewRegistry().
+			Register(IDENT, "IDENT", ybase.CategoryIdentifier).
+			Register(NUM, "NUM", ybase.CategoryLiteral).
+			Register(ybase.ErrorToken, "error", ybase.CategoryUnknown)
+	scan = ybase.NewRuleScanFunc(
+		ybase.Rule{Pattern: ybase.Runes(unicode.IsSpace), Skip: true},
+		ybase.Rule{Type: IDENT, Pattern: ybase.Runes(unicode.IsLetter)},
+		ybase.Rule{Type: NUM, Pattern: ybase.Runes(unicode.IsDigit)},
+	)
+)
+
+func TestRun(t *testing.T) {
+	ybasetest.Run(t, "testdata/*.txtar", scan, ybasetest.WithLexerOptions(ybase.WithRegistry(registry)))
+	ybasetest.Run(t, "testdata/recovery/*.txtar", scan, ybasetest.WithLexerOptions(
+		ybase.WithRegistry(registry),
+		ybase.WithRecovery(unicode.IsSpace),
+	))
+}
+
+func TestRunUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "x.txtar")
+	const fixture = `comment
+-- input --
+ab 1
+-- tokens --
+1:1-1:3 IDENT "ab"
+`
+	assert.Nil(t, os.WriteFile(path, []byte(fixture), 0o644))
+	ybasetest.Run(t, path, scan, ybasetest.WithUpdate(true), ybasetest.WithLexerOptions(ybase.WithRegistry(registry)))
+	got, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, `comment
+-- input --
+ab 1
+-- tokens --
+1:1-1:3 IDENT "ab"
+1:3-1:5 NUM "1"
+`, string(got))
+}
+
+func TestDump(t *testing.T) {
+	got := ybasetest.Dump([]byte("ab\n12?"), scan)
+	assert.Equal(t, strings.Join([]string{
+		`1:1-1:3 1 "ab"`,
+		`1:3-2:3 2 "12"`,
+		`error: 2:3: unexpected '?': NoRuleMatched`,
+		``,
+	}, "\n"), got)
+}
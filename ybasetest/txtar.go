@@ -0,0 +1,116 @@
+package ybasetest
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Archive is a txtar archive:
+//
+//	comment
+//	-- name1 --
+//	data1
+//	-- name2 --
+//	data2
+//
+// The data of a file ends with a newline.
+type Archive struct {
+	Comment []byte
+	Files   []ArchiveFile
+}
+
+// ArchiveFile is a file in Archive.
+type ArchiveFile struct {
+	Name string
+	Data []byte
+}
+
+// ParseArchive parses the txtar archive.
+func ParseArchive(data []byte) *Archive {
+	a := &Archive{}
+	var (
+		name    string
+		hasFile bool
+		buf     []byte
+	)
+	flush := func() {
+		if !hasFile {
+			a.Comment = buf
+		} else {
+			a.Files = append(a.Files, ArchiveFile{
+				Name: name,
+				Data: buf,
+			})
+		}
+		buf = nil
+	}
+	for len(data) > 0 {
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line = data[:i+1]
+		}
+		data = data[len(line):]
+		if x, ok := parseMarker(line); ok {
+			flush()
+			name = x
+			hasFile = true
+			continue
+		}
+		buf = append(buf, line...)
+	}
+	flush()
+	return a
+}
+
+// parseMarker parses the line like "-- name --".
+func parseMarker(line []byte) (string, bool) {
+	s := strings.TrimSuffix(strings.TrimSuffix(string(line), "\n"), "\r")
+	if !strings.HasPrefix(s, "-- ") || !strings.HasSuffix(s, " --") || len(s) < len("-- x --") {
+		return "", false
+	}
+	name := strings.TrimSpace(s[3 : len(s)-3])
+	return name, name != ""
+}
+
+// Format formats the archive.
+// A newline is added to the data that does not end with a newline.
+func (a *Archive) Format() []byte {
+	var b bytes.Buffer
+	b.Write(fixNewline(a.Comment))
+	for _, f := range a.Files {
+		b.WriteString("-- " + f.Name + " --\n")
+		b.Write(fixNewline(f.Data))
+	}
+	return b.Bytes()
+}
+
+func fixNewline(data []byte) []byte {
+	if len(data) == 0 || data[len(data)-1] == '\n' {
+		return data
+	}
+	return append(data[:len(data):len(data)], '\n')
+}
+
+// File returns the data of the file, false if not found.
+func (a *Archive) File(name string) ([]byte, bool) {
+	for _, f := range a.Files {
+		if f.Name == name {
+			return f.Data, true
+		}
+	}
+	return nil, false
+}
+
+// SetFile replaces the data of the file, or appends the file if not found.
+func (a *Archive) SetFile(name string, data []byte) {
+	for i, f := range a.Files {
+		if f.Name == name {
+			a.Files[i].Data = data
+			return
+		}
+	}
+	a.Files = append(a.Files, ArchiveFile{
+		Name: name,
+		Data: data,
+	})
+}
@@ -0,0 +1,64 @@
+package ybasetest_test
+
+import (
+	"testing"
+
+	"github.com/berquerant/ybase/ybasetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchive(t *testing.T) {
+	for _, tc := range []struct {
+		title  string
+		data   string
+		want   *ybasetest.Archive
+		format string
+	}{
+		{
+			title:  "empty",
+			data:   "",
+			want:   &ybasetest.Archive{},
+			format: "",
+		},
+		{
+			title: "comment only",
+			data:  "comment\n",
+			want: &ybasetest.Archive{
+				Comment: []byte("comment\n"),
+			},
+			format: "comment\n",
+		},
+		{
+			title: "files",
+			data:  "comment\n-- a --\nA\n-- b --\n-- c.txt --\nC1\n-- not a marker\nC2",
+			want: &ybasetest.Archive{
+				Comment: []byte("comment\n"),
+				Files: []ybasetest.ArchiveFile{
+					{Name: "a", Data: []byte("A\n")},
+					{Name: "b"},
+					{Name: "c.txt", Data: []byte("C1\n-- not a marker\nC2")},
+				},
+			},
+			format: "comment\n-- a --\nA\n-- b --\n-- c.txt --\nC1\n-- not a marker\nC2\n",
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			got := ybasetest.ParseArchive([]byte(tc.data))
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.format, string(got.Format()))
+		})
+	}
+
+	t.Run("File", func(t *testing.T) {
+		a := ybasetest.ParseArchive([]byte("-- a --\nA\n"))
+		data, ok := a.File("a")
+		assert.True(t, ok)
+		assert.Equal(t, "A\n", string(data))
+		_, ok = a.File("b")
+		assert.False(t, ok)
+
+		a.SetFile("a", []byte("AA\n"))
+		a.SetFile("b", []byte("B"))
+		assert.Equal(t, "-- a --\nAA\n-- b --\nB\n", string(a.Format()))
+	})
+}